@@ -24,7 +24,7 @@
 import * as path from 'node:path';
 import {
   dbg, errLog, readState, writeState, pruneState, loadApiKey,
-  readTranscript, extractMessages, buildContent,
+  readTranscript, extractMessages, buildContent, toTag,
   apiPost, readHookInput,
   detectPlatform, initPlatformPaths, normalizeEvent,
   type TranscriptEntry,
@@ -140,7 +140,7 @@ async function main(): Promise<void> {
     dbg(TAG, `adopting manual save — convId="${manualSave.convId}" title="${manualSave.title}"`);
   }
 
-  const tags = [platformName, 'auto-captured', projectName];
+  const tags = [platformName, 'auto-captured', toTag(projectName)];
   const metadata = {
     source: `${platformName}_${event.toLowerCase()}_hook`,
     session_id,
@@ -300,6 +300,14 @@ export function buildContent(messages: Message[]): string {
     .join('\n\n');
 }
 
+/**
+ * Same rule as canonicalTag() in lib/tags.ts ("My Project" -> "my-project") —
+ * duplicated because hooks are copied standalone into ~/.claude/hooks.
+ */
+export function toTag(value: string): string {
+  return value.trim().toLowerCase().replace(/\s+/g, '-');
+}
+
 // ─── HTTP helpers ────────────────────────────────────────────────────────────
 
 export function apiGet(apiKey: string, urlPath: string, timeout = 8000): Promise<Record<string, unknown> | null> {
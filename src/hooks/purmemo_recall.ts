@@ -175,7 +175,7 @@ async function main(): Promise<void> {
   });
   const [memResult, todosResult] = await Promise.all([
     apiGet(apiKey, `/api/v1/memories/?${params}`),
-    apiGet(apiKey, `/api/v1/todos?limit=${MAX_TODOS}`).catch(() => null),
+    apiGet(apiKey, `/api/v1/todos?${new URLSearchParams({ limit: String(MAX_TODOS) })}`).catch(() => null),
   ]);
   const memories = (memResult as { memories?: Array<Record<string, unknown>> })?.memories || [];
   const todos = (Array.isArray(todosResult) ? todosResult : (todosResult as { todos?: Array<Record<string, unknown>> })?.todos) || [];
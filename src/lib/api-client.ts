@@ -2,23 +2,36 @@
 /**
  * API client utilities for purmemo MCP server.
  *
- * Exports: sanitizeUnicode, makeApiCall, safeErrorMessage,
- *          CircuitBreaker, CircuitBreakerOpenError, apiCircuitBreaker
+ * Exports: sanitizeUnicode, makeApiCall, buildQuery, safeErrorMessage, parseErrorBody,
+ *          CircuitBreaker, CircuitBreakerOpenError, apiCircuitBreaker,
+ *          BaseUrlFailover, withApiKey
  *
- * Call initApiClient({ apiUrl }) before first makeApiCall.
+ * Call initApiClient({ apiUrl, fallbackUrls, userAgent }) before first makeApiCall.
  */
 
+import { AsyncLocalStorage } from 'async_hooks';
 import { structuredLog } from './logger.js';
 
 // ============================================================================
 // Module state — set via initApiClient()
 // ============================================================================
 
-let API_URL = '';
+let apiFailover = null;
+let USER_AGENT = 'purmemo-mcp';
 let _resolveApiKey = () => null;
 
-export function initApiClient({ apiUrl, resolveApiKey }) {
-  API_URL = apiUrl;
+// Per-request API key for multi-user (remote) mode. Scoped to the async call
+// chain, so concurrent sessions never see each other's credentials.
+const requestApiKey = new AsyncLocalStorage();
+
+/** Runs fn with every makeApiCall inside it authenticated as apiKey. */
+export function withApiKey(apiKey, fn) {
+  return requestApiKey.run(apiKey, fn);
+}
+
+export function initApiClient({ apiUrl, resolveApiKey, userAgent, fallbackUrls = [] }) {
+  apiFailover = new BaseUrlFailover([apiUrl, ...fallbackUrls]);
+  if (userAgent) USER_AGENT = userAgent;
   if (resolveApiKey) _resolveApiKey = resolveApiKey;
 }
 
@@ -27,8 +40,9 @@ export function initApiClient({ apiUrl, resolveApiKey }) {
 // ============================================================================
 
 export class CircuitBreaker {
-  constructor(name, failureThreshold = 5, recoveryTimeout = 60000) {
+  constructor(name, failureThreshold = 5, recoveryTimeout = 60000, now = Date.now) {
     this.name = name;
+    this.now = now; // injectable clock for deterministic tests
     this.failureThreshold = failureThreshold;
     this.recoveryTimeout = recoveryTimeout;
     this.failureCount = 0;
@@ -45,7 +59,7 @@ export class CircuitBreaker {
 
     // Check for OPEN → HALF_OPEN transition
     if (this.state === 'OPEN') {
-      if (Date.now() - this.openedAt >= this.recoveryTimeout) {
+      if (this.now() - this.openedAt >= this.recoveryTimeout) {
         this.state = 'HALF_OPEN';
         structuredLog.info('Circuit breaker entering HALF_OPEN', { circuit_breaker: this.name });
       } else {
@@ -75,15 +89,15 @@ export class CircuitBreaker {
   _onFailure(error) {
     this.failureCount++;
     this.totalFailures++;
-    this.lastFailureTime = Date.now();
+    this.lastFailureTime = this.now();
 
     if (this.state === 'HALF_OPEN') {
       this.state = 'OPEN';
-      this.openedAt = Date.now();
+      this.openedAt = this.now();
       structuredLog.warn('Circuit breaker reopened', { circuit_breaker: this.name, error: error.message });
     } else if (this.failureCount >= this.failureThreshold && this.state === 'CLOSED') {
       this.state = 'OPEN';
-      this.openedAt = Date.now();
+      this.openedAt = this.now();
       structuredLog.error('Circuit breaker opened', { circuit_breaker: this.name, failures: this.failureCount });
     }
   }
@@ -110,6 +124,68 @@ export class CircuitBreakerOpenError extends Error {
 
 export const apiCircuitBreaker = new CircuitBreaker('purmemo-api', 5, 60000);
 
+// ============================================================================
+// Base URL Failover
+// ============================================================================
+
+/**
+ * Switches to the next base URL after sustained 5xx/timeouts on the active one.
+ * Sticky: stays on the fallback until recoveryTimeout elapses, then retries the
+ * primary (and fails over again if it is still unhealthy).
+ *
+ * Only makeApiCall goes through failover, and server.ts passes no fallback URLs
+ * in remote mode: there the proxy, resources and auth calls fetch
+ * PURMEMO_API_URL directly, so the local handlers must stay on it too.
+ */
+export class BaseUrlFailover {
+  constructor(urls, failureThreshold = 3, recoveryTimeout = 300000, now = Date.now) {
+    this.urls = urls.filter(Boolean);
+    this.now = now;
+    this.failureThreshold = failureThreshold;
+    this.recoveryTimeout = recoveryTimeout;
+    this.activeIndex = 0;
+    this.failureCount = 0;
+    this.switchedAt = null;
+  }
+
+  current() {
+    if (this.activeIndex !== 0 && this.now() - this.switchedAt >= this.recoveryTimeout) {
+      this.activeIndex = 0;
+      this.failureCount = 0;
+      this.switchedAt = null;
+      structuredLog.info('API failover retrying primary base URL', { api_url: this.urls[0] });
+    }
+    return this.urls[this.activeIndex];
+  }
+
+  recordSuccess(url) {
+    if (url === this.urls[this.activeIndex]) this.failureCount = 0;
+  }
+
+  recordFailure(url) {
+    // Ignore late results from a URL we already switched away from
+    if (url !== this.urls[this.activeIndex]) return;
+    this.failureCount++;
+    if (this.urls.length > 1 && this.failureCount >= this.failureThreshold) {
+      const from = this.urls[this.activeIndex];
+      this.activeIndex = (this.activeIndex + 1) % this.urls.length;
+      this.failureCount = 0;
+      this.switchedAt = this.now();
+      structuredLog.warn('API failover switched base URL', { from, to: this.urls[this.activeIndex] });
+    }
+  }
+
+  getStatus() {
+    return {
+      activeUrl: this.urls[this.activeIndex],
+      activeIndex: this.activeIndex,
+      urls: this.urls,
+      failureCount: this.failureCount,
+      switchedAt: this.switchedAt ? new Date(this.switchedAt).toISOString() : null
+    };
+  }
+}
+
 // ============================================================================
 // Safe Error Message Helper
 // ============================================================================
@@ -130,6 +206,76 @@ export function safeErrorMessage(error) {
   return 'An error occurred while processing your request. Please try again.';
 }
 
+// ============================================================================
+// Error Body Parsing
+// ============================================================================
+
+const MAX_ERROR_DETAIL_LENGTH = 300;
+
+function clipErrorText(text) {
+  const clean = String(text)
+    .replace(/[\x00-\x08\x0B\x0C\x0E-\x1F\x7F]/g, '')
+    .replace(/\s+/g, ' ')
+    .trim();
+  return clean.length > MAX_ERROR_DETAIL_LENGTH ? `${clean.substring(0, MAX_ERROR_DETAIL_LENGTH)}…` : clean;
+}
+
+// FastAPI 422: [{ loc: ['body', 'title'], msg: 'field required', type: ... }]
+function formatValidationErrors(items) {
+  return items
+    .map(item => {
+      if (!item || typeof item !== 'object') return String(item);
+      const loc = Array.isArray(item.loc) ? item.loc.filter(part => part !== 'body').join('.') : '';
+      const msg = item.msg || item.message || JSON.stringify(item);
+      return loc ? `${loc}: ${msg}` : msg;
+    })
+    .join('; ');
+}
+
+function describeErrorPayload(payload) {
+  if (payload === null || payload === undefined) return '';
+  if (typeof payload === 'string') return payload;
+  if (Array.isArray(payload)) return formatValidationErrors(payload);
+  if (typeof payload !== 'object') return String(payload);
+  for (const key of ['detail', 'message', 'error', 'error_description']) {
+    if (payload[key] !== undefined && payload[key] !== null) {
+      const described = describeErrorPayload(payload[key]);
+      if (described) return described;
+    }
+  }
+  return JSON.stringify(payload);
+}
+
+/**
+ * Turns an error response body into a short, single-line message. Handles
+ * FastAPI {"detail": ...} (string, object or validation array), other JSON
+ * error shapes, HTML error pages from proxies/CDNs, empty bodies, and
+ * truncated or non-JSON text. Never throws.
+ */
+export function parseErrorBody(text) {
+  if (typeof text !== 'string' || !text.trim()) return 'Empty response body';
+  const trimmed = text.trim();
+
+  // JSON first: validation errors echo user input, which may itself be HTML
+  if (trimmed.startsWith('{') || trimmed.startsWith('[') || trimmed.startsWith('"')) {
+    try {
+      return clipErrorText(describeErrorPayload(JSON.parse(trimmed))) || 'Empty error detail';
+    } catch {
+      // Truncated or otherwise invalid JSON — fall through to raw text
+      return clipErrorText(trimmed) || 'Unreadable response body';
+    }
+  }
+
+  if (/^<(!doctype|html|head|body)\b/i.test(trimmed) || /<\/(html|body|title)>/i.test(trimmed)) {
+    const title = trimmed.match(/<title[^>]*>([\s\S]*?)<\/title>/i)?.[1];
+    const heading = trimmed.match(/<h1[^>]*>([\s\S]*?)<\/h1>/i)?.[1];
+    const summary = (title || heading || '').replace(/<[^>]+>/g, '');
+    return clipErrorText(summary ? `HTML error page: ${summary}` : 'HTML error page');
+  }
+
+  return clipErrorText(trimmed) || 'Unreadable response body';
+}
+
 // ============================================================================
 // Unicode Sanitization
 // ============================================================================
@@ -160,6 +306,29 @@ export function sanitizeUnicode(text) {
   }
 }
 
+// ============================================================================
+// Query String Encoding
+// ============================================================================
+
+/**
+ * Encodes a filter object as a query string ("?a=1&b=x" or "").
+ * Skips undefined/null/empty values and joins arrays with commas, so callers can
+ * pass optional filters straight through instead of building strings by hand.
+ */
+export function buildQuery(params = {}) {
+  const search = new URLSearchParams();
+  for (const [key, value] of Object.entries(params)) {
+    if (value === undefined || value === null || value === '') continue;
+    if (Array.isArray(value)) {
+      if (value.length > 0) search.set(key, value.join(','));
+      continue;
+    }
+    search.set(key, String(value));
+  }
+  const query = search.toString();
+  return query ? `?${query}` : '';
+}
+
 // ============================================================================
 // API Call with Circuit Breaker + Timeout
 // ============================================================================
@@ -169,13 +338,14 @@ export function sanitizeUnicode(text) {
 export async function makeApiCall(endpoint, options = {}, apiKeyOverride = null) {
   const method = options.method || 'GET';
   const requestId = `api_${Date.now()}_${Math.random().toString(36).substr(2, 6)}`;
-  const effectiveKey = apiKeyOverride || _resolveApiKey();
+  const effectiveKey = apiKeyOverride || requestApiKey.getStore() || _resolveApiKey();
+  const baseUrl = apiFailover.current();
 
   structuredLog.info('API call starting', {
     request_id: requestId,
     method,
     endpoint,
-    api_url: API_URL,
+    api_url: baseUrl,
     api_key_configured: !!effectiveKey
   });
 
@@ -189,15 +359,24 @@ export async function makeApiCall(endpoint, options = {}, apiKeyOverride = null)
     const timeoutId = setTimeout(() => controller.abort(), 30000);
 
     try {
-      const response = await fetch(`${API_URL}${endpoint}`, {
-        ...options,
-        signal: controller.signal,
-        headers: {
-          'Authorization': `Bearer ${effectiveKey}`,
-          'Content-Type': 'application/json',
-          ...options.headers
-        }
-      });
+      let response;
+      try {
+        response = await fetch(`${baseUrl}${endpoint}`, {
+          ...options,
+          signal: controller.signal,
+          headers: {
+            'Authorization': `Bearer ${effectiveKey}`,
+            'Content-Type': 'application/json',
+            'User-Agent': USER_AGENT,
+            ...options.headers
+          }
+        });
+      } catch (fetchError) {
+        // Timeouts and network failures count toward failover; errors from
+        // handling a response we did get (e.g. a null JSON body) do not
+        apiFailover.recordFailure(baseUrl);
+        throw fetchError;
+      }
 
       clearTimeout(timeoutId);
 
@@ -208,6 +387,13 @@ export async function makeApiCall(endpoint, options = {}, apiKeyOverride = null)
         status_text: response.statusText
       });
 
+      // 4xx means the server is reachable — only 5xx counts toward failover
+      if (response.status >= 500) {
+        apiFailover.recordFailure(baseUrl);
+      } else {
+        apiFailover.recordSuccess(baseUrl);
+      }
+
       if (!response.ok) {
         const errorText = await response.text();
         structuredLog.warn('API error response', {
@@ -263,16 +449,18 @@ export async function makeApiCall(endpoint, options = {}, apiKeyOverride = null)
           );
         }
 
-        throw new Error(`API Error ${response.status}: ${errorText}`);
+        throw new Error(`API Error ${response.status}: ${parseErrorBody(errorText)}`);
       }
 
-      const data = await response.json();
+      // Parse from text so the size log doesn't re-serialize large memory payloads
+      const responseText = await response.text();
+      const data = JSON.parse(responseText);
 
       structuredLog.info('API call successful', {
         request_id: requestId,
         endpoint,
         response_keys: Object.keys(data).length,
-        response_size_bytes: JSON.stringify(data).length
+        response_size_bytes: responseText.length
       });
 
       return data;
@@ -0,0 +1,33 @@
+/**
+ * Bounded concurrency helper shared by chunked saves and batch fetches.
+ *
+ * Exports: mapWithConcurrency
+ */
+
+/**
+ * Runs fn over items with at most `limit` in flight; results keep input order.
+ * Stops scheduling new items after the first rejection (like a sequential loop
+ * would); calls already in flight are allowed to settle.
+ */
+export async function mapWithConcurrency<T, R>(
+  items: T[],
+  limit: number,
+  fn: (item: T, index: number) => Promise<R>
+): Promise<R[]> {
+  const results: R[] = new Array(items.length);
+  let next = 0;
+  let failed = false;
+  const workers = Array.from({ length: Math.min(limit, items.length) }, async () => {
+    while (!failed && next < items.length) {
+      const index = next++;
+      try {
+        results[index] = await fn(items[index], index);
+      } catch (error) {
+        failed = true;
+        throw error;
+      }
+    }
+  });
+  await Promise.all(workers);
+  return results;
+}
@@ -0,0 +1,69 @@
+/**
+ * Mounted credential file support for purmemo MCP server.
+ *
+ * PURMEMO_API_KEY_FILE points at a file holding the raw API key (e.g. a
+ * Kubernetes secret volume). The file is polled rather than fs.watch()ed:
+ * secret volumes rotate by swapping a symlink, which inotify watchers on the
+ * old target never see.
+ *
+ * Exports: readCredentialFile, watchCredentialFile, resolveConfiguredApiKey
+ */
+
+import * as fs from 'fs';
+import { structuredLog } from './logger.js';
+
+const DEFAULT_POLL_INTERVAL_MS = 10000;
+
+/** Reads and trims the key; returns null if the file is missing or empty. */
+export function readCredentialFile(filePath: string): string | null {
+  try {
+    const key = fs.readFileSync(filePath, 'utf8').trim();
+    return key || null;
+  } catch (err) {
+    structuredLog.warn('Could not read API key file', { path: filePath, error_message: (err as Error).message });
+    return null;
+  }
+}
+
+/**
+ * Polls filePath and calls onChange(newKey) whenever a non-empty key different
+ * from the last one appears. An empty or missing file keeps the previous key,
+ * so a rotation caught mid-write never blanks out credentials.
+ * Returns a stop() function.
+ */
+export function watchCredentialFile(
+  filePath: string,
+  onChange: (key: string) => void,
+  intervalMs: number = DEFAULT_POLL_INTERVAL_MS
+): () => void {
+  let lastKey = readCredentialFile(filePath);
+
+  const listener = (curr: fs.Stats, prev: fs.Stats) => {
+    if (curr.mtimeMs === prev.mtimeMs && curr.ino === prev.ino) return;
+    const key = readCredentialFile(filePath);
+    if (!key || key === lastKey) return;
+    lastKey = key;
+    structuredLog.info('API key file changed, using rotated key', { path: filePath });
+    onChange(key);
+  };
+
+  // persistent: false — the watcher must not keep the process alive on its own
+  fs.watchFile(filePath, { interval: intervalMs, persistent: false }, listener);
+  return () => fs.unwatchFile(filePath, listener);
+}
+
+/**
+ * Resolves the operator-configured key: PURMEMO_API_KEY wins over
+ * PURMEMO_API_KEY_FILE. Returns { key, source } with source 'env', 'key_file'
+ * or null when neither yields a key (callers then fall back to the token store).
+ */
+export function resolveConfiguredApiKey(
+  env: NodeJS.ProcessEnv = process.env
+): { key: string | null; source: 'env' | 'key_file' | null } {
+  if (env.PURMEMO_API_KEY) return { key: env.PURMEMO_API_KEY, source: 'env' };
+  if (env.PURMEMO_API_KEY_FILE) {
+    const key = readCredentialFile(env.PURMEMO_API_KEY_FILE);
+    if (key) return { key, source: 'key_file' };
+  }
+  return { key: null, source: null };
+}
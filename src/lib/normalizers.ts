@@ -0,0 +1,75 @@
+/**
+ * Pre-save content normalizers for purmemo MCP server.
+ *
+ * Each normalizer is a pure (text) => text function. normalizeContent() runs a
+ * list of them in order, so callers compose exactly the cleanup they want.
+ * The pipeline is opt-in: nothing runs unless PURMEMO_NORMALIZERS lists names
+ * (comma-separated); resolveNormalizers() parses that value.
+ */
+
+export type Normalizer = (text: string) => string;
+
+// CSI (colors, cursor movement) and OSC (titles, hyperlinks) escape sequences
+const ANSI_PATTERN = /\u001b\[[0-?]*[ -/]*[@-~]|\u001b\][^\u0007\u001b]*(?:\u0007|\u001b\\)|\u001b[@-Z\\-_]/g;
+
+/** Removes terminal escape codes left in raw agent/CLI output. */
+export const stripAnsi: Normalizer = (text) => text.replace(ANSI_PATTERN, '');
+
+/** Canonical Unicode composition so visually identical text matches in search. */
+export const normalizeUnicode: Normalizer = (text) => text.normalize('NFC');
+
+/**
+ * Normalizes line endings, drops trailing spaces, and caps blank-line runs at one.
+ * Leading indentation is preserved so code blocks keep their shape.
+ */
+export const collapseWhitespace: Normalizer = (text) => text
+  .replace(/\r\n?/g, '\n')
+  .replace(/[ \t]+$/gm, '')
+  .replace(/\n{3,}/g, '\n\n');
+
+const HTML_ENTITIES: Record<string, string> = {
+  '&amp;': '&', '&lt;': '<', '&gt;': '>', '&quot;': '"', '&#39;': "'", '&nbsp;': ' '
+};
+
+/**
+ * Converts common HTML markup (headings, emphasis, links, lists, code) to Markdown
+ * and drops remaining tags. Intended for pasted web content — opt-in only, since
+ * conversations legitimately discuss HTML.
+ */
+export const htmlToMarkdown: Normalizer = (text) => text
+  .replace(/<(script|style)\b[^>]*>[\s\S]*?<\/\1>/gi, '')
+  .replace(/<pre\b[^>]*>\s*<code\b[^>]*>([\s\S]*?)<\/code>\s*<\/pre>/gi, '\n```\n$1\n```\n')
+  .replace(/<h([1-6])\b[^>]*>([\s\S]*?)<\/h\1>/gi, (_m, level, inner) => `\n${'#'.repeat(Number(level))} ${inner.trim()}\n`)
+  .replace(/<(strong|b)\b[^>]*>([\s\S]*?)<\/\1>/gi, '**$2**')
+  .replace(/<(em|i)\b[^>]*>([\s\S]*?)<\/\1>/gi, '*$2*')
+  .replace(/<code\b[^>]*>([\s\S]*?)<\/code>/gi, '`$1`')
+  .replace(/<a\b[^>]*href="([^"]*)"[^>]*>([\s\S]*?)<\/a>/gi, '[$2]($1)')
+  .replace(/<li\b[^>]*>/gi, '\n- ')
+  .replace(/<br\s*\/?>/gi, '\n')
+  .replace(/<\/(p|div|ul|ol|li|tr|table|blockquote)>/gi, '\n')
+  .replace(/<[^>]+>/g, '')
+  .replace(/&(amp|lt|gt|quot|#39|nbsp);/g, (entity) => HTML_ENTITIES[entity]);
+
+export const NORMALIZERS: Record<string, Normalizer> = {
+  ansi: stripAnsi,
+  unicode: normalizeUnicode,
+  whitespace: collapseWhitespace,
+  html: htmlToMarkdown
+};
+
+/**
+ * Resolves a comma-separated normalizer list ("ansi,whitespace") to functions.
+ * Unknown names are ignored; empty or "none" means no normalization, so stored
+ * content only changes when the operator asks for it.
+ */
+export function resolveNormalizers(spec: string | undefined): Normalizer[] {
+  const trimmed = (spec || '').trim().toLowerCase();
+  if (!trimmed || trimmed === 'none') return [];
+  return trimmed.split(',').map(n => n.trim()).filter(n => n in NORMALIZERS).map(n => NORMALIZERS[n]);
+}
+
+/** Runs text through each normalizer in order. */
+export function normalizeContent(text: string, normalizers: Normalizer[]): string {
+  if (!text || typeof text !== 'string') return text;
+  return normalizers.reduce((current, normalize) => normalize(current), text);
+}
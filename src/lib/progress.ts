@@ -0,0 +1,60 @@
+/**
+ * Progress reporting for long-running tool calls.
+ *
+ * Handlers accept a ProgressReporter and call it as work completes. Over MCP,
+ * createMcpProgressReporter() turns those calls into notifications/progress
+ * messages when the client sent a progressToken; otherwise reporting is a no-op.
+ */
+
+import type { ProgressReporter } from '../types.js';
+import { structuredLog } from './logger.js';
+
+type SendNotification = (notification: { method: string; params: Record<string, unknown> }) => Promise<void>;
+
+export const noopProgress: ProgressReporter = {
+  onStart() {},
+  onItem() {},
+  onError() {},
+  onFinish() {}
+};
+
+/**
+ * Reports progress as MCP notifications/progress for the given token.
+ * Notification failures are logged and swallowed — progress must never fail a tool call.
+ */
+export function createMcpProgressReporter(
+  progressToken: string | number | undefined,
+  sendNotification: SendNotification | undefined
+): ProgressReporter {
+  if (progressToken === undefined || !sendNotification) return noopProgress;
+
+  let total = 0;
+  let lastSent = -1;
+  const send = (progress: number, message?: string) => {
+    // MCP requires progress to increase with each notification
+    if (progress <= lastSent) return;
+    lastSent = progress;
+    const params: Record<string, unknown> = { progressToken, progress };
+    if (total > 0) params.total = total;
+    if (message) params.message = message;
+    sendNotification({ method: 'notifications/progress', params }).catch((err: Error) => {
+      structuredLog.debug('Progress notification failed', { error_message: err.message });
+    });
+  };
+
+  return {
+    onStart(count, message) {
+      total = count;
+      send(0, message);
+    },
+    onItem(completed, message) {
+      send(completed, message);
+    },
+    onError(error, message) {
+      structuredLog.warn('Progress step failed', { error_message: error.message, step: message });
+    },
+    onFinish(message) {
+      send(total, message);
+    }
+  };
+}
@@ -0,0 +1,86 @@
+/**
+ * Tag helpers for purmemo MCP server.
+ *
+ * PURMEMO_TAG_POLICY picks what happens to caller-supplied tags that break the
+ * naming rules (lowercase, hyphenated, unique, at most MAX_TAGS):
+ *   off        save as given (default)
+ *   warn       save as given, list what would change in the save result
+ *   normalize  save normalizeTags() output, list what changed
+ *   reject     fail the save, list what to fix
+ *
+ * Exports: MAX_TAGS, TagPolicy, resolveTagPolicy, canonicalTag, normalizeTags,
+ *          applyTagPolicy, formatTagIssues
+ */
+
+export const MAX_TAGS = 20;
+
+export type TagPolicy = 'off' | 'warn' | 'normalize' | 'reject';
+
+const TAG_POLICIES: TagPolicy[] = ['off', 'warn', 'normalize', 'reject'];
+
+/** Parses PURMEMO_TAG_POLICY; unset or unknown values mean 'off'. */
+export function resolveTagPolicy(spec: string | undefined): TagPolicy {
+  const value = (spec || '').trim().toLowerCase() as TagPolicy;
+  return TAG_POLICIES.includes(value) ? value : 'off';
+}
+
+/** Trimmed, lowercase, inner whitespace hyphenated: "Bug Fix" -> "bug-fix". */
+export function canonicalTag(tag: unknown): string {
+  return String(tag ?? '').trim().toLowerCase().replace(/\s+/g, '-');
+}
+
+/**
+ * Canonical form of a tag list: empties and duplicates dropped, first maxTags
+ * kept.
+ */
+export function normalizeTags(tags: unknown[], maxTags: number = MAX_TAGS): string[] {
+  return [...new Set(tags.map(canonicalTag).filter(Boolean))].slice(0, maxTags);
+}
+
+export interface TagPolicyResult {
+  /** Tags to save */
+  tags: string[];
+  /** One entry per tag the naming rules would rename or drop */
+  issues: string[];
+  /** True when policy is 'reject' and there are issues */
+  rejected: boolean;
+}
+
+/** Applies policy to caller-supplied tags (system tags are added afterwards). */
+export function applyTagPolicy(tags: unknown[], policy: TagPolicy, maxTags: number = MAX_TAGS): TagPolicyResult {
+  const given = tags.map(tag => String(tag ?? ''));
+  if (policy === 'off') return { tags: given, issues: [], rejected: false };
+
+  const kept = new Set<string>();
+  const issues: string[] = [];
+  for (const tag of given) {
+    const canonical = canonicalTag(tag);
+    if (!canonical) {
+      issues.push(`"${tag}" (empty)`);
+    } else if (kept.has(canonical)) {
+      issues.push(`"${tag}" (duplicate of "${canonical}")`);
+    } else if (kept.size >= maxTags) {
+      issues.push(`"${tag}" (over the ${maxTags}-tag limit)`);
+    } else {
+      kept.add(canonical);
+      if (canonical !== tag) issues.push(`"${tag}" → "${canonical}"`);
+    }
+  }
+
+  return {
+    tags: policy === 'normalize' ? [...kept] : given,
+    issues,
+    rejected: policy === 'reject' && issues.length > 0
+  };
+}
+
+/** Human-readable note for the save result; empty when there is nothing to report. */
+export function formatTagIssues(result: TagPolicyResult, policy: TagPolicy): string {
+  if (result.issues.length === 0) return '';
+  const list = result.issues.map(issue => `- ${issue}`).join('\n');
+  if (policy === 'reject') {
+    return `❌ Tags rejected by tag policy — use unique, lowercase, hyphenated tags (max ${MAX_TAGS}):\n${list}`;
+  }
+  if (policy === 'normalize') return `🏷️ Tags normalized (renamed or dropped):\n${list}`;
+  return `⚠️ Tags saved as given, but don't match the naming rules:\n${list}`;
+}
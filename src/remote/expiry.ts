@@ -0,0 +1,46 @@
+/**
+ * TTL pruning for remote mode's in-memory stores (sessions, OAuth states,
+ * refresh tokens). The clock is passed in so expiry can be tested without
+ * waiting on real timers.
+ */
+
+export type Clock = () => number;
+
+/** Streamable HTTP sessions idle longer than this are dropped */
+export const SESSION_IDLE_TTL_MS = 30 * 60 * 1000;
+
+/** OAuth states for abandoned sign-ins */
+export const OAUTH_STATE_TTL_MS = 10 * 60 * 1000;
+
+/** Refresh tokens kept for silent re-auth */
+export const REFRESH_TOKEN_TTL_MS = 24 * 60 * 60 * 1000;
+
+/**
+ * Deletes entries whose `field` timestamp is more than ttlMs before now().
+ * Works on both Maps and plain-object stores; returns the number removed.
+ */
+export function pruneExpired<T extends Record<string, any>>(
+  store: Map<string, T> | Record<string, T>,
+  ttlMs: number,
+  now: Clock,
+  field: string = 'createdAt'
+): number {
+  const cutoff = now() - ttlMs;
+  let removed = 0;
+  if (store instanceof Map) {
+    for (const [key, entry] of Array.from(store)) {
+      if (entry[field] < cutoff) {
+        store.delete(key);
+        removed++;
+      }
+    }
+  } else {
+    for (const key of Object.keys(store)) {
+      if (store[key][field] < cutoff) {
+        delete store[key];
+        removed++;
+      }
+    }
+  }
+  return removed;
+}
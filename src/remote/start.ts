@@ -7,7 +7,7 @@
  */
 
 import { structuredLog } from '../lib/logger.js';
-import { apiCircuitBreaker } from '../lib/api-client.js';
+import { apiCircuitBreaker, buildQuery, withApiKey } from '../lib/api-client.js';
 import {
   handleSaveConversation,
   handleSaveArtifact,
@@ -17,15 +17,19 @@ import {
   handleShareMemory,
   handleRecallPublic,
   handleGetPublicMemory,
-  handleReportMemory
+  handleReportMemory,
+  parseBatchMemoryIds,
+  fetchMemoryDetailsBatch
 } from '../tools/handlers.js';
 import { handleGenerateHandoffBrief } from '../tools/handoff.js';
+import { pruneExpired, SESSION_IDLE_TTL_MS, OAUTH_STATE_TTL_MS, REFRESH_TOKEN_TTL_MS } from './expiry.js';
 
 export async function startRemoteServer(ctx) {
   // Destructure all server.ts dependencies — same variable names, zero body changes
   const {
     API_URL,
     CLIENT_VERSION,
+    USER_AGENT,
     PLATFORM,
     TOOLS,
     RESOURCES,
@@ -35,7 +39,9 @@ export async function startRemoteServer(ctx) {
     getResolvedApiKey,
     setResolvedApiKey,
     resolveApiKey,
-    checkForUpdates
+    checkForUpdates,
+    // Clock for session, OAuth state and refresh token lifetimes; injectable for tests
+    now: clock = Date.now
   } = ctx;
 
   // Alias for code that reads resolvedApiKey directly
@@ -92,7 +98,7 @@ export async function startRemoteServer(ctx) {
     let backendLatency = null;
     try {
       const t0 = Date.now();
-      const resp = await fetch(`${API_URL}/health`, { signal: AbortSignal.timeout(5000) });
+      const resp = await fetch(`${API_URL}/health`, { headers: { 'User-Agent': USER_AGENT }, signal: AbortSignal.timeout(5000) });
       backendLatency = Date.now() - t0;
       backendStatus = resp.ok ? 'healthy' : 'unhealthy';
     } catch { backendStatus = 'unreachable'; }
@@ -155,15 +161,7 @@ export async function startRemoteServer(ctx) {
 
   // Session cleanup — remove stale sessions every 5 minutes (matches Python)
   const sessionCleanupInterval = setInterval(() => {
-    const maxAge = 30 * 60 * 1000; // 30 minutes
-    const now = Date.now();
-    let cleaned = 0;
-    for (const [sid, sess] of mcpSessions) {
-      if (now - sess.lastActivity > maxAge) {
-        mcpSessions.delete(sid);
-        cleaned++;
-      }
-    }
+    const cleaned = pruneExpired(mcpSessions, SESSION_IDLE_TTL_MS, clock, 'lastActivity');
     if (cleaned > 0) structuredLog.info('Cleaned up stale sessions', { count: cleaned });
   }, 5 * 60 * 1000);
 
@@ -174,29 +172,13 @@ export async function startRemoteServer(ctx) {
     const token = auth.split(' ')[1];
     try {
       const resp = await fetch(`${API_URL}/api/v1/auth/me`, {
-        headers: { 'Authorization': `Bearer ${token}`, 'User-Agent': `purmemo-mcp/${CLIENT_VERSION}` },
+        headers: { 'Authorization': `Bearer ${token}`, 'User-Agent': USER_AGENT },
         signal: AbortSignal.timeout(10000)
       });
       if (resp.ok) return token;
       // Silent token refresh if 401 and we have a refresh token
-      if (resp.status === 401 && refreshTokenStore[token]?.token) {
-        try {
-          const refreshResp = await fetch(`${API_URL}/api/v1/auth/refresh`, {
-            method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ refresh_token: refreshTokenStore[token].token }),
-            signal: AbortSignal.timeout(10000)
-          });
-          if (refreshResp.ok) {
-            const data = await refreshResp.json();
-            const newToken = data.access_token || data.api_key;
-            if (newToken) {
-              if (data.refresh_token) refreshTokenStore[newToken] = { token: data.refresh_token, createdAt: Date.now() };
-              delete refreshTokenStore[token];
-              return newToken;
-            }
-          }
-        } catch {}
+      if (resp.status === 401) {
+        try { return await refreshAccessToken(token); } catch {}
       }
       return null;
     } catch { return null; }
@@ -244,9 +226,12 @@ export async function startRemoteServer(ctx) {
 
   // Helper: execute a tool call (proxies to backend or handles locally)
   async function executeToolForRemote(toolName, toolArgs, apiKey) {
-    // Track tool usage
+    // Track tool usage — once per client call, so a batch counts as one
     toolCallCounts[toolName] = (toolCallCounts[toolName] || 0) + 1;
+    return runToolForRemote(toolName, toolArgs, apiKey);
+  }
 
+  async function runToolForRemote(toolName, toolArgs, apiKey) {
     // Tools that MUST be handled locally (not available on backend)
     const localOnlyHandlers = {
       'get_user_context': handleGetUserContext,
@@ -263,12 +248,23 @@ export async function startRemoteServer(ctx) {
 
     const localHandler = localOnlyHandlers[toolName];
     if (localHandler) {
-      // Use per-request API key for the handler call (concurrency-safe)
-      const effectiveKey = apiKey || resolvedApiKey;
-      try { return await localHandler(toolArgs, effectiveKey); }
+      // Scope the caller's API key to this handler's async chain (concurrency-safe);
+      // without one, makeApiCall falls back to the server-level key
+      try { return await withApiKey(apiKey, () => localHandler(toolArgs)); }
       catch (e) { return { content: [{ type: 'text', text: `Error: ${e.message}` }] }; }
     }
 
+    // Batch get_memory_details — backend takes one ID per call, so fan out here
+    // with the same bounded helper (and ID parsing) as stdio mode
+    if (toolName === 'get_memory_details' && toolArgs?.memoryIds) {
+      const { ids, requestedCount } = parseBatchMemoryIds(toolArgs);
+      return fetchMemoryDetailsBatch(ids, requestedCount, async (id) => {
+        const result = await runToolForRemote(toolName, { ...toolArgs, memoryIds: undefined, memoryId: id }, apiKey);
+        if (result?.error) return { isError: true, content: [{ type: 'text', text: `❌ ${result.error}` }] };
+        return result?.content ? result : { content: [{ type: 'text', text: JSON.stringify(result?.data || result, null, 2) }] };
+      });
+    }
+
     // recall_memories, get_memory_details, discover_related_conversations
     // proxy to backend — ChatGPT widgets parse the backend's response format
     try {
@@ -277,7 +273,7 @@ export async function startRemoteServer(ctx) {
         headers: {
           'Authorization': `Bearer ${apiKey}`,
           'Content-Type': 'application/json',
-          'User-Agent': `purmemo-mcp/${CLIENT_VERSION}`,
+          'User-Agent': USER_AGENT,
           'X-MCP-Version': CLIENT_VERSION
         },
         body: JSON.stringify({ tool: toolName, arguments: toolArgs }),
@@ -286,40 +282,27 @@ export async function startRemoteServer(ctx) {
 
       if (resp.status === 401) {
         // Silent token refresh — try refreshing before telling user to reconnect
-        if (refreshTokenStore[apiKey]?.token) {
-          try {
-            const refreshResp = await fetch(`${API_URL}/api/v1/auth/refresh`, {
+        try {
+          const newToken = await refreshAccessToken(apiKey);
+          if (newToken) {
+            // Retry the tool call with new token
+            const retryResp = await fetch(`${API_URL}/api/v10/mcp/tools/execute`, {
               method: 'POST',
-              headers: { 'Content-Type': 'application/json' },
-              body: JSON.stringify({ refresh_token: refreshTokenStore[apiKey].token }),
-              signal: AbortSignal.timeout(10000)
+              headers: {
+                'Authorization': `Bearer ${newToken}`,
+                'Content-Type': 'application/json',
+                'User-Agent': USER_AGENT
+              },
+              body: JSON.stringify({ tool: toolName, arguments: toolArgs }),
+              signal: AbortSignal.timeout(30000)
             });
-            if (refreshResp.ok) {
-              const refreshData = await refreshResp.json();
-              const newToken = refreshData.access_token || refreshData.api_key;
-              if (newToken) {
-                if (refreshData.refresh_token) refreshTokenStore[newToken] = { token: refreshData.refresh_token, createdAt: Date.now() };
-                delete refreshTokenStore[apiKey];
-                // Retry the tool call with new token
-                const retryResp = await fetch(`${API_URL}/api/v10/mcp/tools/execute`, {
-                  method: 'POST',
-                  headers: {
-                    'Authorization': `Bearer ${newToken}`,
-                    'Content-Type': 'application/json',
-                    'User-Agent': `purmemo-mcp/${CLIENT_VERSION}`
-                  },
-                  body: JSON.stringify({ tool: toolName, arguments: toolArgs }),
-                  signal: AbortSignal.timeout(30000)
-                });
-                if (retryResp.ok) {
-                  structuredLog.info('Silent token refresh succeeded', { tool: toolName });
-                  return await retryResp.json();
-                }
-              }
+            if (retryResp.ok) {
+              structuredLog.info('Silent token refresh succeeded', { tool: toolName });
+              return await retryResp.json();
             }
-          } catch (e) {
-            structuredLog.warn('Silent token refresh failed', { error: e.message });
           }
+        } catch (e) {
+          structuredLog.warn('Silent token refresh failed', { error: e.message });
         }
         return {
           isError: true,
@@ -388,7 +371,7 @@ export async function startRemoteServer(ctx) {
           });
         }
         const sessionId = randomUUID();
-        mcpSessions.set(sessionId, { token: apiKey, createdAt: Date.now(), lastActivity: Date.now() });
+        mcpSessions.set(sessionId, { token: apiKey, createdAt: clock(), lastActivity: clock() });
         connectionCount++;
         connMonitor.trackConnection(sessionId, { type: 'streamable-http' });
 
@@ -426,7 +409,7 @@ export async function startRemoteServer(ctx) {
       let apiKey = null;
       if (sessionId && mcpSessions.has(sessionId)) {
         apiKey = mcpSessions.get(sessionId).token;
-        mcpSessions.get(sessionId).lastActivity = Date.now();
+        mcpSessions.get(sessionId).lastActivity = clock();
       } else {
         apiKey = await validateApiKeyFromRequest(req);
       }
@@ -471,14 +454,14 @@ export async function startRemoteServer(ctx) {
 
         // Memory resources — proxy to backend
         try {
-          const authHeaders = { 'Authorization': `Bearer ${apiKey}`, 'User-Agent': `purmemo-mcp/${CLIENT_VERSION}` };
+          const authHeaders = { 'Authorization': `Bearer ${apiKey}`, 'User-Agent': USER_AGENT };
           let text = '', mimeType = 'text/plain';
 
           if (uri === 'memory://me') {
             const [meResp, statsResp, memsResp, sessResp] = await Promise.allSettled([
               fetch(`${API_URL}/api/v1/auth/me`, { headers: authHeaders, signal: AbortSignal.timeout(10000) }),
               fetch(`${API_URL}/api/v1/stats/`, { headers: authHeaders, signal: AbortSignal.timeout(10000) }),
-              fetch(`${API_URL}/api/v1/memories/?limit=20&sort=created_at&order=desc`, { headers: authHeaders, signal: AbortSignal.timeout(10000) }),
+              fetch(`${API_URL}/api/v1/memories/${buildQuery({ limit: 20, sort: 'created_at', order: 'desc' })}`, { headers: authHeaders, signal: AbortSignal.timeout(10000) }),
               fetch(`${API_URL}/api/v1/identity/session`, { headers: authHeaders, signal: AbortSignal.timeout(10000) })
             ]);
             const me = meResp.status === 'fulfilled' && meResp.value.ok ? await meResp.value.json() : {};
@@ -514,12 +497,12 @@ export async function startRemoteServer(ctx) {
             // Delegate to existing handlers via makeApiCall
             try {
               if (uri === 'memory://context') {
-                const data = await fetch(`${API_URL}/api/v1/memories/?limit=5&sort=created_at&order=desc`, { headers: authHeaders, signal: AbortSignal.timeout(10000) });
+                const data = await fetch(`${API_URL}/api/v1/memories/${buildQuery({ limit: 5, sort: 'created_at', order: 'desc' })}`, { headers: authHeaders, signal: AbortSignal.timeout(10000) });
                 const mems = data.ok ? await data.json() : [];
                 const memList = Array.isArray(mems) ? mems : (mems.memories || []);
                 text = memList.map((m, i) => `${i + 1}. **${m.title || 'Untitled'}** (${new Date(m.created_at).toLocaleDateString()})\n   ${(m.content || '').substring(0, 150)}...`).join('\n\n');
               } else if (uri === 'memory://projects') {
-                const data = await fetch(`${API_URL}/api/v1/memories/?limit=20&sort=created_at&order=desc`, { headers: authHeaders, signal: AbortSignal.timeout(10000) });
+                const data = await fetch(`${API_URL}/api/v1/memories/${buildQuery({ limit: 20, sort: 'created_at', order: 'desc' })}`, { headers: authHeaders, signal: AbortSignal.timeout(10000) });
                 const mems = data.ok ? await data.json() : [];
                 const memList = Array.isArray(mems) ? mems : (mems.memories || []);
                 const byProj = {};
@@ -601,9 +584,16 @@ export async function startRemoteServer(ctx) {
           return sendSSE(res, { jsonrpc: '2.0', id: requestId, result });
         }
 
-        // Normal result — wrap in content if needed
+        // Normal result — wrap in content if needed; keep structuredContent/_meta for widgets
         const content = result?.content || [{ type: 'text', text: JSON.stringify(result?.data || result, null, 2) }];
-        return sendSSE(res, { jsonrpc: '2.0', id: requestId, result: { content } });
+        return sendSSE(res, {
+          jsonrpc: '2.0', id: requestId,
+          result: {
+            content,
+            ...(result?.structuredContent ? { structuredContent: result.structuredContent } : {}),
+            ...(result?._meta ? { _meta: result._meta } : {})
+          }
+        });
       }
 
       // ── Unknown method ──
@@ -627,7 +617,7 @@ export async function startRemoteServer(ctx) {
 
     const sessionId = req.headers['mcp-session-id'] || randomUUID();
     if (!mcpSessions.has(sessionId)) {
-      mcpSessions.set(sessionId, { token: apiKey, createdAt: Date.now(), lastActivity: Date.now() });
+      mcpSessions.set(sessionId, { token: apiKey, createdAt: clock(), lastActivity: clock() });
     }
 
     res.writeHead(200, {
@@ -804,15 +794,41 @@ export async function startRemoteServer(ctx) {
 
   // Clean up abandoned OAuth states (>10 min) and expired refresh tokens (>24 hr)
   setInterval(() => {
-    const now = Date.now();
-    for (const key of Object.keys(oauthStateStorage)) {
-      if (now - oauthStateStorage[key].createdAt > 600_000) delete oauthStateStorage[key];
-    }
-    for (const key of Object.keys(refreshTokenStore)) {
-      if (now - refreshTokenStore[key].createdAt > 86_400_000) delete refreshTokenStore[key];
-    }
+    pruneExpired(oauthStateStorage, OAUTH_STATE_TTL_MS, clock);
+    pruneExpired(refreshTokenStore, REFRESH_TOKEN_TTL_MS, clock);
   }, 300_000); // every 5 minutes
 
+  // Silent refresh, single-flight per expired token: refresh tokens are single-use,
+  // so concurrent 401s (e.g. a batch fan-out) must share one refresh call. The
+  // settled result is kept briefly for requests that were already in flight.
+  const pendingRefreshes = new Map();
+  function refreshAccessToken(expiredToken) {
+    if (!pendingRefreshes.has(expiredToken)) {
+      const refresh = (async () => {
+        const stored = refreshTokenStore[expiredToken];
+        if (!stored?.token) return null;
+        const refreshResp = await fetch(`${API_URL}/api/v1/auth/refresh`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json', 'User-Agent': USER_AGENT },
+          body: JSON.stringify({ refresh_token: stored.token }),
+          signal: AbortSignal.timeout(10000)
+        });
+        if (!refreshResp.ok) return null;
+        const data = await refreshResp.json();
+        const newToken = data.access_token || data.api_key;
+        if (!newToken) return null;
+        if (data.refresh_token) refreshTokenStore[newToken] = { token: data.refresh_token, createdAt: clock() };
+        delete refreshTokenStore[expiredToken];
+        return newToken;
+      })();
+      pendingRefreshes.set(expiredToken, refresh);
+      refresh.catch(() => null).finally(() => {
+        setTimeout(() => pendingRefreshes.delete(expiredToken), 60_000).unref();
+      });
+    }
+    return pendingRefreshes.get(expiredToken);
+  }
+
   // Rate limiter (per-IP, leaky bucket)
   const rateLimits = {};
   function checkRateLimit(ip, endpoint, limit, windowSec = 60) {
@@ -880,7 +896,7 @@ export async function startRemoteServer(ctx) {
         const apiKey = Buffer.from(session, 'base64').toString('utf8');
         // Validate against backend
         const meResp = await fetch(`${API_URL}/api/v1/auth/me`, {
-          headers: { 'Authorization': `Bearer ${apiKey}`, 'User-Agent': `purmemo-mcp/${CLIENT_VERSION}` },
+          headers: { 'Authorization': `Bearer ${apiKey}`, 'User-Agent': USER_AGENT },
           signal: AbortSignal.timeout(10000)
         });
         if (meResp.ok) {
@@ -933,7 +949,7 @@ export async function startRemoteServer(ctx) {
     try {
       const authResp = await fetch(`${API_URL}/api/v1/auth/login`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json', 'User-Agent': `purmemo-mcp/${CLIENT_VERSION}` },
+        headers: { 'Content-Type': 'application/json', 'User-Agent': USER_AGENT },
         body: JSON.stringify({ email, password }),
         signal: AbortSignal.timeout(10000)
       });
@@ -946,7 +962,7 @@ export async function startRemoteServer(ctx) {
       const apiKey = authData.api_key || authData.access_token;
       if (!apiKey) return res.status(500).send('No API key returned');
 
-      if (authData.refresh_token) refreshTokenStore[apiKey] = { token: authData.refresh_token, createdAt: Date.now() };
+      if (authData.refresh_token) refreshTokenStore[apiKey] = { token: authData.refresh_token, createdAt: clock() };
       const sessionParam = Buffer.from(apiKey).toString('base64');
 
       if (params) {
@@ -976,7 +992,7 @@ export async function startRemoteServer(ctx) {
     try {
       const regResp = await fetch(`${API_URL}/api/v1/auth/register`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json', 'User-Agent': `purmemo-mcp/${CLIENT_VERSION}` },
+        headers: { 'Content-Type': 'application/json', 'User-Agent': USER_AGENT },
         body: JSON.stringify({ email, password }),
         signal: AbortSignal.timeout(10000)
       });
@@ -990,7 +1006,7 @@ export async function startRemoteServer(ctx) {
         const loginUrl = params ? `/login?params=${params}&signup_complete=1` : '/login?signup_complete=1';
         return res.redirect(loginUrl);
       }
-      if (authData.refresh_token) refreshTokenStore[apiKey] = { token: authData.refresh_token, createdAt: Date.now() };
+      if (authData.refresh_token) refreshTokenStore[apiKey] = { token: authData.refresh_token, createdAt: clock() };
       const sessionParam = Buffer.from(apiKey).toString('base64');
 
       if (params) {
@@ -1020,7 +1036,7 @@ export async function startRemoteServer(ctx) {
       const { email } = req.body;
       const resp = await fetch(`${API_URL}/api/v1/auth/check-email`, {
         method: 'POST',
-        headers: { 'Content-Type': 'application/json', 'User-Agent': `purmemo-mcp/${CLIENT_VERSION}` },
+        headers: { 'Content-Type': 'application/json', 'User-Agent': USER_AGENT },
         body: JSON.stringify({ email }),
         signal: AbortSignal.timeout(10000)
       });
@@ -1035,7 +1051,7 @@ export async function startRemoteServer(ctx) {
     const stateId = randomUUID();
     let statePayload = stateId;
     if (params) {
-      oauthStateStorage[stateId] = { params, provider: 'google', createdAt: Date.now() };
+      oauthStateStorage[stateId] = { params, provider: 'google', createdAt: clock() };
       statePayload = Buffer.from(JSON.stringify({ id: stateId, params, provider: 'google' })).toString('base64url');
     }
     const callbackUrl = `https://${req.get('host')}/oauth/callback`;
@@ -1048,7 +1064,7 @@ export async function startRemoteServer(ctx) {
     const stateId = randomUUID();
     let statePayload = stateId;
     if (params) {
-      oauthStateStorage[stateId] = { params, provider: 'github', createdAt: Date.now() };
+      oauthStateStorage[stateId] = { params, provider: 'github', createdAt: clock() };
       statePayload = Buffer.from(JSON.stringify({ id: stateId, params, provider: 'github' })).toString('base64url');
     }
     const callbackUrl = `https://${req.get('host')}/oauth/callback`;
@@ -1083,13 +1099,13 @@ export async function startRemoteServer(ctx) {
 
       // Validate token against backend
       const meResp = await fetch(`${API_URL}/api/v1/auth/me`, {
-        headers: { 'Authorization': `Bearer ${token}` },
+        headers: { 'Authorization': `Bearer ${token}`, 'User-Agent': USER_AGENT },
         signal: AbortSignal.timeout(10000)
       });
       if (!meResp.ok) return res.status(401).send('Invalid token');
 
       // Store refresh token
-      if (callbackRefreshToken) refreshTokenStore[token] = { token: callbackRefreshToken, createdAt: Date.now() };
+      if (callbackRefreshToken) refreshTokenStore[token] = { token: callbackRefreshToken, createdAt: clock() };
 
       // Generate MCP authorization code
       const authCode = generateCode();
@@ -1134,7 +1150,7 @@ export async function startRemoteServer(ctx) {
     }
 
     const [apiKey, storedRefreshToken] = result;
-    if (storedRefreshToken) refreshTokenStore[apiKey] = { token: storedRefreshToken, createdAt: Date.now() };
+    if (storedRefreshToken) refreshTokenStore[apiKey] = { token: storedRefreshToken, createdAt: clock() };
 
     res.json({
       access_token: apiKey,
@@ -64,8 +64,13 @@ import {
   apiCircuitBreaker,
   safeErrorMessage,
   sanitizeUnicode,
-  makeApiCall
+  makeApiCall,
+  buildQuery
 } from './lib/api-client.js';
+import { createMcpProgressReporter } from './lib/progress.js';
+import { resolveNormalizers } from './lib/normalizers.js';
+import { MAX_TAGS, resolveTagPolicy } from './lib/tags.js';
+import { resolveConfiguredApiKey, watchCredentialFile } from './lib/credential-file.js';
 import {
   initHandlers,
   handleSaveConversation,
@@ -101,11 +106,14 @@ if (['setup', 'init', 'status', 'logout', 'hooks'].includes(_subcommand)) {
 
 const API_URL = (process.env.PURMEMO_API_URL || 'https://api.purmemo.ai').replace(/\/+$/, '');
 
-// Initialize extracted API client with URL + lazy key resolver
-initApiClient({
-  apiUrl: API_URL,
-  resolveApiKey: () => resolvedApiKey
-});
+// Optional comma-separated fallback base URLs (e.g. a secondary region), tried in
+// order after sustained 5xx/timeouts on the active URL
+const API_FALLBACK_URLS = (process.env.PURMEMO_API_FALLBACK_URLS || '')
+  .split(',')
+  .map(u => u.trim().replace(/\/+$/, ''))
+  .filter(Boolean);
+
+const REMOTE_MODE = process.argv.includes('--remote') || process.env.PURMEMO_REMOTE === '1';
 
 // ============================================================================
 // Version check — runs once on startup, non-blocking
@@ -121,6 +129,25 @@ try { CLIENT_VERSION = require('./package.json').version; } catch {
   try { CLIENT_VERSION = require('../package.json').version; } catch { /* unknown */ }
 }
 
+// User-Agent sent on every API call. PURMEMO_USER_AGENT_SUFFIX lets operators
+// tag which integration is generating load (e.g. "acme-bot/2.3").
+const USER_AGENT_SUFFIX = (process.env.PURMEMO_USER_AGENT_SUFFIX || '').trim();
+const USER_AGENT = `purmemo-mcp/${CLIENT_VERSION}` + (USER_AGENT_SUFFIX ? ` ${USER_AGENT_SUFFIX}` : '');
+
+// Telemetry opt-out: the startup version check runs anonymously (no User-Agent
+// identifying version or integration). Honors the DO_NOT_TRACK convention.
+const TELEMETRY_DISABLED = process.env.PURMEMO_DISABLE_TELEMETRY === '1' || process.env.DO_NOT_TRACK === '1';
+
+// Initialize extracted API client with URL + lazy key resolver.
+// Failover is stdio-only: remote mode's proxy, resources and auth always use
+// API_URL, so its tool handlers must too or writes and reads split regions.
+initApiClient({
+  apiUrl: API_URL,
+  fallbackUrls: REMOTE_MODE ? [] : API_FALLBACK_URLS,
+  userAgent: USER_AGENT,
+  resolveApiKey: () => resolvedApiKey
+});
+
 let _updateNotice = null; // set to a string if an update is required
 
 function semverLt(a, b) {
@@ -135,8 +162,13 @@ function semverLt(a, b) {
 }
 
 async function checkForUpdates() {
+  // Still runs when telemetry is disabled: min_required_version is a compatibility
+  // warning, not usage tracking — only the identifying header is dropped
   try {
-    const res = await fetch(`${API_URL}/api/v1/mcp/version`, { signal: AbortSignal.timeout(5000) });
+    const res = await fetch(`${API_URL}/api/v1/mcp/version`, {
+      headers: TELEMETRY_DISABLED ? {} : { 'User-Agent': USER_AGENT },
+      signal: AbortSignal.timeout(5000)
+    });
     if (!res.ok) return;
     const data = await res.json();
     const { latest_version, min_required_version, update_instructions } = data;
@@ -164,8 +196,14 @@ function readCurrentSessionId() {
   }
 }
 
-// API key resolution: env var wins, then ~/.purmemo/auth.json (set by `npx purmemo-mcp setup`)
-let resolvedApiKey = process.env.PURMEMO_API_KEY || null;
+// API key resolution: env var wins, then PURMEMO_API_KEY_FILE, then ~/.purmemo/auth.json (set by `npx purmemo-mcp setup`)
+const API_KEY_FILE = process.env.PURMEMO_API_KEY_FILE || null;
+let resolvedApiKey = resolveConfiguredApiKey().key;
+
+// Mounted secrets rotate in place — pick up the new key without a restart
+if (API_KEY_FILE && !process.env.PURMEMO_API_KEY) {
+  watchCredentialFile(API_KEY_FILE, (key) => { resolvedApiKey = key; });
+}
 
 // Last recall result cache — maps ordinal "1"-"N" to UUID for get_memory_details
 let lastRecallIds = [];
@@ -174,7 +212,7 @@ let lastRecallIds = [];
 structuredLog.info('API configuration loaded', {
   api_url: API_URL,
   api_key_present: !!resolvedApiKey,
-  api_key_source: resolvedApiKey ? 'env' : 'pending'
+  api_key_source: process.env.PURMEMO_API_KEY ? 'env' : (resolvedApiKey ? 'key_file' : 'pending')
 });
 
 // Platform detection: user specifies via MCP_PLATFORM env var
@@ -221,7 +259,11 @@ initHandlers({
   platform: PLATFORM,
   getLastRecallIds: () => lastRecallIds,
   setLastRecallIds: (ids) => { lastRecallIds = ids; },
-  readCurrentSessionId
+  readCurrentSessionId,
+  // PURMEMO_NORMALIZERS: comma-separated (ansi, unicode, whitespace, html); unset means none
+  normalizers: resolveNormalizers(process.env.PURMEMO_NORMALIZERS),
+  // PURMEMO_TAG_POLICY: off (default), warn, normalize or reject
+  tagPolicy: resolveTagPolicy(process.env.PURMEMO_TAG_POLICY)
 });
 
 // ULTIMATE TOOL DEFINITIONS
@@ -336,7 +378,7 @@ const TOOLS = [
         tags: {
           type: 'array',
           items: { type: 'string' },
-          description: 'Tags for categorization',
+          description: `Tags for categorization. Use unique, lowercase, hyphenated tags (e.g. "bug-fix"), at most ${MAX_TAGS}; depending on server policy, others may be normalized or rejected.`,
           default: ['complete-conversation']
         },
         priority: {
@@ -398,7 +440,7 @@ is to preserve artifacts that would otherwise be lost or summarized. Minimum 100
         tags: {
           type: 'array',
           items: { type: 'string' },
-          description: 'Optional tags for categorization',
+          description: `Optional tags for categorization. Use unique, lowercase, hyphenated tags (e.g. "bug-fix"), at most ${MAX_TAGS}; depending on server policy, others may be normalized or rejected.`,
           default: []
         }
       },
@@ -521,7 +563,7 @@ is to preserve artifacts that would otherwise be lost or summarized. Minimum 100
       'openai/widgetAccessible': true,
       'openai/widgetDomain': 'detail.widgets.purmemo.ai'
     },
-    description: 'Get complete details of a specific memory, including all linked parts if chunked',
+    description: 'Get complete details of a specific memory, including all linked parts if chunked. Pass memoryIds instead of memoryId to load several memories in one call (results keep the requested order).',
     inputSchema: {
       type: 'object',
       properties: {
@@ -529,6 +571,12 @@ is to preserve artifacts that would otherwise be lost or summarized. Minimum 100
           type: 'string',
           description: 'UUID of the memory to retrieve, OR an ordinal number ("1", "2", etc.) referencing the position from the last recall_memories result'
         },
+        memoryIds: {
+          type: 'array',
+          items: { type: 'string' },
+          maxItems: 20,
+          description: 'Optional: retrieve up to 20 memories at once (UUIDs or ordinals). Fetched concurrently; a failure on one ID does not affect the others.'
+        },
         includeLinkedParts: {
           type: 'boolean',
           default: true,
@@ -545,7 +593,7 @@ is to preserve artifacts that would otherwise be lost or summarized. Minimum 100
           description: 'Maximum characters per page (default 80000, min 1000, max 500000). Reduce for faster responses on slow connections.'
         }
       },
-      required: ['memoryId']
+      required: []
     }
   },
   {
@@ -1300,8 +1348,10 @@ function withUpdateNotice(result) {
   };
 }
 
-server.setRequestHandler(CallToolRequestSchema, async (request) => {
+server.setRequestHandler(CallToolRequestSchema, async (request, extra) => {
   const { name, arguments: args } = request.params;
+  // Only sends notifications when the client supplied a progressToken
+  const progress = createMcpProgressReporter(request.params._meta?.progressToken, extra?.sendNotification);
 
   // Track tool usage (for remote mode health endpoint)
   if (typeof toolCallCounts !== 'undefined') {
@@ -1310,13 +1360,13 @@ server.setRequestHandler(CallToolRequestSchema, async (request) => {
 
   switch (name) {
     case 'save_conversation':
-      return withUpdateNotice(await handleSaveConversation(args));
+      return withUpdateNotice(await handleSaveConversation(args, progress));
     case 'save_artifact':
       return withUpdateNotice(await handleSaveArtifact(args));
     case 'recall_memories':
       return withUpdateNotice(await handleRecallMemories(args));
     case 'get_memory_details':
-      return withUpdateNotice(await handleGetMemoryDetails(args));
+      return withUpdateNotice(await handleGetMemoryDetails(args, progress));
     case 'discover_related_conversations':
       return withUpdateNotice(await handleDiscoverRelated(args));
     case 'get_user_context':
@@ -1379,7 +1429,7 @@ server.setRequestHandler(ReadResourceRequestSchema, async (request) => {
       const [meResp, statsResp, memoriesResp, sessionResp] = await Promise.allSettled([
         makeApiCall('/api/v1/auth/me'),
         makeApiCall('/api/v1/stats/'),
-        makeApiCall('/api/v1/memories/?limit=20&sort=created_at&order=desc'),
+        makeApiCall(`/api/v1/memories/${buildQuery({ limit: 20, sort: 'created_at', order: 'desc' })}`),
         makeApiCall('/api/v1/identity/session'),
       ]);
 
@@ -1431,7 +1481,7 @@ server.setRequestHandler(ReadResourceRequestSchema, async (request) => {
 
     } else if (uri === 'memory://context') {
       // 5 most recent memories as a human-readable briefing
-      data = await makeApiCall('/api/v1/memories/?limit=5&sort=created_at&order=desc');
+      data = await makeApiCall(`/api/v1/memories/${buildQuery({ limit: 5, sort: 'created_at', order: 'desc' })}`);
       const mems = Array.isArray(data) ? data : (data.memories || []);
       const skipPrefixes = ['===', '[', 'USER:', 'ASSISTANT:', 'user:', 'assistant:', '# ', '## '];
       const lines = ['## My Recent Work Context\n'];
@@ -1457,7 +1507,7 @@ server.setRequestHandler(ReadResourceRequestSchema, async (request) => {
 
     } else if (uri === 'memory://projects') {
       // Active projects grouped by name, sorted by most recent activity
-      data = await makeApiCall('/api/v1/memories/?limit=20&sort=created_at&order=desc');
+      data = await makeApiCall(`/api/v1/memories/${buildQuery({ limit: 20, sort: 'created_at', order: 'desc' })}`);
       const mems = Array.isArray(data) ? data : (data.memories || []);
       const projectMap = {};
       for (const m of mems) {
@@ -1629,17 +1679,22 @@ server.setRequestHandler(GetPromptRequestSchema, async (request) => {
 });
 
 // ============================================================================
-// Startup: resolve API key (env var → ~/.purmemo/auth.json) then connect
+// Startup: resolve API key (env var → key file → ~/.purmemo/auth.json) then connect
 // ============================================================================
 
 async function resolveApiKey() {
-  // Priority 1: explicit env var
-  if (process.env.PURMEMO_API_KEY) {
+  // Priority 1 and 2: explicit env var, then mounted key file (kept current by watchCredentialFile)
+  const configured = resolveConfiguredApiKey();
+  if (configured.source === 'env') {
     structuredLog.info('API key resolved from environment variable');
-    return process.env.PURMEMO_API_KEY;
+    return configured.key;
+  }
+  if (configured.source === 'key_file') {
+    structuredLog.info('API key resolved from PURMEMO_API_KEY_FILE', { path: API_KEY_FILE });
+    return configured.key;
   }
 
-  // Priority 2: token saved by `npx purmemo-mcp setup`
+  // Priority 3: token saved by `npx purmemo-mcp setup`
   try {
     const tokenStore = new TokenStore();
     const token = await tokenStore.getToken();
@@ -1654,17 +1709,32 @@ async function resolveApiKey() {
   return null;
 }
 
+// PURMEMO_VALIDATE_ON_START=1: probe the key before accepting MCP traffic, so a
+// bad deployment exits at startup instead of returning 401s on the first tool call
+const VALIDATE_ON_START = process.env.PURMEMO_VALIDATE_ON_START === '1';
+
+async function validateApiKey(apiKey) {
+  if (!apiKey) {
+    throw new Error('No API key configured (set PURMEMO_API_KEY or PURMEMO_API_KEY_FILE, or run `npx purmemo-mcp setup`)');
+  }
+  try {
+    const me = await makeApiCall('/api/v1/auth/me');
+    structuredLog.info('API key validated', { tier: me.tier || 'free' });
+  } catch (error) {
+    throw new Error(`API key validation failed: ${error.message}`);
+  }
+}
+
 // ============================================================================
 // STARTUP — Stdio (default) or Remote HTTP (--remote / PURMEMO_REMOTE=1)
 // ============================================================================
 
-const REMOTE_MODE = process.argv.includes('--remote') || process.env.PURMEMO_REMOTE === '1';
-
 if (REMOTE_MODE) {
   const { startRemoteServer } = await import('./remote/start.js');
   await startRemoteServer({
     API_URL,
     CLIENT_VERSION,
+    USER_AGENT,
     PLATFORM,
     TOOLS,
     RESOURCES,
@@ -1684,7 +1754,7 @@ if (REMOTE_MODE) {
 
   // If running interactively in a terminal (not piped by an MCP client) and
   // no auth is configured, redirect to setup instead of silently hanging.
-  if (process.stdin.isTTY && !process.env.PURMEMO_API_KEY) {
+  if (process.stdin.isTTY && !process.env.PURMEMO_API_KEY && !API_KEY_FILE) {
     const _ts = new TokenStore();
     const _tok = await _ts.getToken();
     if (!_tok?.access_token) {
@@ -1699,8 +1769,9 @@ if (REMOTE_MODE) {
 
   resolveApiKey().then(apiKey => {
     resolvedApiKey = apiKey;
-    return server.connect(transport);
+    if (VALIDATE_ON_START) return validateApiKey(apiKey);
   })
+    .then(() => server.connect(transport))
     .then(() => {
       checkForUpdates();
       structuredLog.info('Purmemo MCP Server started successfully', {
@@ -1709,7 +1780,7 @@ if (REMOTE_MODE) {
         tier: '4-resources-prompts',
         api_url: API_URL,
         api_key_configured: !!resolvedApiKey,
-        api_key_source: process.env.PURMEMO_API_KEY ? 'env_var' : (resolvedApiKey ? 'token_store' : 'none'),
+        api_key_source: process.env.PURMEMO_API_KEY ? 'env_var' : (API_KEY_FILE && resolvedApiKey ? 'key_file' : (resolvedApiKey ? 'token_store' : 'none')),
         platform: PLATFORM,
         tools_count: TOOLS.length,
         circuit_breaker_enabled: true,
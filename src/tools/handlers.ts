@@ -7,7 +7,11 @@
  */
 
 import { structuredLog } from '../lib/logger.js';
-import { makeApiCall, sanitizeUnicode, safeErrorMessage } from '../lib/api-client.js';
+import { makeApiCall, buildQuery, sanitizeUnicode, safeErrorMessage } from '../lib/api-client.js';
+import { noopProgress } from '../lib/progress.js';
+import { mapWithConcurrency } from '../lib/concurrency.js';
+import { normalizeContent } from '../lib/normalizers.js';
+import { applyTagPolicy, formatTagIssues, type TagPolicy } from '../lib/tags.js';
 import {
   extractProjectContext,
   generateIntelligentTitle,
@@ -26,17 +30,25 @@ import {
 
 let PLATFORM = 'claude';
 let readCurrentSessionId: () => string | null = () => null;
+// Applied to saved content after Unicode sanitization; empty unless configured (see lib/normalizers.ts)
+let contentNormalizers = [];
+// What to do with caller tags that break the naming rules (see lib/tags.ts)
+let tagPolicy: TagPolicy = 'off';
 
 export function initHandlers(deps: {
   platform: string;
   getLastRecallIds: () => string[];
   setLastRecallIds: (ids: string[]) => void;
   readCurrentSessionId: () => string | null;
+  normalizers?: Array<(text: string) => string>;
+  tagPolicy?: TagPolicy;
 }) {
   PLATFORM = deps.platform;
   _getLastRecallIds = deps.getLastRecallIds;
   _setLastRecallIds = deps.setLastRecallIds;
   readCurrentSessionId = deps.readCurrentSessionId;
+  if (deps.normalizers) contentNormalizers = deps.normalizers;
+  if (deps.tagPolicy) tagPolicy = deps.tagPolicy;
 }
 
 // lastRecallIds is mutable shared state — use getter/setter to keep server.ts as owner
@@ -53,6 +65,7 @@ function extractContentMetadata(content) {
     wordCount: content.split(/\s+/).length,
     hasCodeBlocks: false,
     codeBlockCount: 0,
+    codeLanguages: [],
     hasArtifacts: false,
     artifactCount: 0,
     hasUrls: false,
@@ -67,6 +80,12 @@ function extractContentMetadata(content) {
   if (codeMatches) {
     metadata.hasCodeBlocks = true;
     metadata.codeBlockCount = codeMatches.length;
+    // Fence info strings (```ts, ```python) — unique, lowercased, in first-seen order
+    const languages = codeMatches
+      .map(block => (block.match(/^```([\w#+.-]+)/) || [])[1])
+      .filter(Boolean)
+      .map(lang => lang.toLowerCase());
+    metadata.codeLanguages = [...new Set(languages)];
   }
 
   // Count conversation turns (USER:/ASSISTANT: patterns)
@@ -100,6 +119,10 @@ function extractContentMetadata(content) {
   return metadata;
 }
 
+function formatCodeLanguages(metadata) {
+  return metadata.codeLanguages.length > 0 ? ` (${metadata.codeLanguages.join(', ')})` : '';
+}
+
 function shouldChunk(content) {
   // Auto-chunk if content is over 15K characters
   return content.length > 15000;
@@ -145,7 +168,10 @@ function chunkContent(content, maxChunkSize = 20000) {
   return chunks;
 }
 
-async function saveChunkedContent(content, title, tags = [], metadata = {}) {
+// Parts are independent upserts (distinct conversation_id), so they can upload in parallel
+const CHUNK_UPLOAD_CONCURRENCY = 3;
+
+async function saveChunkedContent(content, title, tags = [], metadata = {}, progress = noopProgress) {
   // Derive a deterministic session ID from the conversation_id (title slug).
   // This ensures re-saves of the same conversation overwrite existing chunks
   // via the backend's ON CONFLICT (user_id, platform, conversation_id) upsert,
@@ -165,12 +191,14 @@ async function saveChunkedContent(content, title, tags = [], metadata = {}) {
     total_parts: totalParts
   });
 
-  const savedParts = [];
+  // Total steps = parts + the index memory
+  progress.onStart(totalParts + 1, `Saving ${totalParts} parts`);
 
-  // Save each chunk — uses deterministic conversation_id so re-saves upsert
-  for (let i = 0; i < chunks.length; i++) {
+  // Save chunks concurrently — uses deterministic conversation_id so re-saves upsert.
+  // savedParts stays in part order regardless of completion order.
+  let completedParts = 0;
+  const savedParts = await mapWithConcurrency(chunks, CHUNK_UPLOAD_CONCURRENCY, async (chunk, i) => {
     const partNumber = i + 1;
-    const chunk = chunks[i];
 
     const partData = await makeApiCall('/api/v1/memories/', {
       method: 'POST',
@@ -193,16 +221,19 @@ async function saveChunkedContent(content, title, tags = [], metadata = {}) {
     });
 
     const partMemoryId = partData.id || partData.memory_id;
-    savedParts.push({ partNumber, memoryId: partMemoryId, size: chunk.length });
 
     structuredLog.debug('Chunk saved', {
       session_id: sessionId,
       part_number: partNumber,
       total_parts: totalParts,
       chunk_size: chunk.length,
-      memory_id: partData.id || partData.memory_id
+      memory_id: partMemoryId
     });
-  }
+    completedParts++;
+    progress.onItem(completedParts, `Saved part ${partNumber}/${totalParts}`);
+
+    return { partNumber, memoryId: partMemoryId, size: chunk.length };
+  });
 
   // If re-chunk count decreased (e.g., content got shorter), orphaned parts
   // from previous saves remain but won't be linked. They'll be naturally
@@ -236,6 +267,8 @@ async function saveChunkedContent(content, title, tags = [], metadata = {}) {
     total_parts: totalParts,
     index_memory_id: indexData.id || indexData.memory_id
   });
+  progress.onItem(totalParts + 1, 'Saved index');
+  progress.onFinish(`Saved ${totalParts} parts and index`);
 
   return {
     sessionId,
@@ -263,7 +296,7 @@ async function saveSingleContent(content, title, tags = [], metadata = {}) {
   const payload: Record<string, unknown> = {
     content,
     title,
-    tags: [...tags, 'complete-conversation'],
+    tags: [...new Set([...tags, 'complete-conversation'])],
     platform: PLATFORM,
     conversation_id: metadata.conversationId || null,
     mode: metadata._mode || 'replace',
@@ -323,7 +356,7 @@ function formatWisdomSuggestion(wisdomSuggestion) {
 // Tool handlers
 // ============================================================================
 
-export async function handleSaveConversation(args) {
+export async function handleSaveConversation(args, progress = noopProgress) {
   const toolName = 'save_conversation';
   const requestId = `${toolName}_${Date.now()}_${Math.random().toString(36).substr(2, 6)}`;
   const startTime = Date.now();
@@ -335,7 +368,7 @@ export async function handleSaveConversation(args) {
 
   try {
     const rawContent = args.conversationContent || '';
-    const content = sanitizeUnicode(rawContent);
+    const content = normalizeContent(sanitizeUnicode(rawContent), contentNormalizers);
     const contentLength = content.length;
 
     structuredLog.debug('Extracting intelligent context', {
@@ -362,7 +395,12 @@ export async function handleSaveConversation(args) {
     if (typeof rawTags === 'string') {
       try { rawTags = JSON.parse(rawTags); } catch { rawTags = [rawTags]; }
     }
-    const tags: string[] = Array.isArray(rawTags) ? rawTags : (rawTags ? [String(rawTags)] : ['complete-conversation']);
+    const tagResult = applyTagPolicy(Array.isArray(rawTags) ? rawTags : (rawTags ? [String(rawTags)] : ['complete-conversation']), tagPolicy);
+    const tagNote = formatTagIssues(tagResult, tagPolicy);
+    if (tagResult.rejected) {
+      return { isError: true, content: [{ type: 'text', text: tagNote }] };
+    }
+    const tags: string[] = tagResult.tags;
 
     let conversationId = args.conversationId;
     if (!conversationId && title && !title.startsWith('Conversation 202')) {
@@ -444,7 +482,7 @@ export async function handleSaveConversation(args) {
 
     // Identity Layer: attach session context to new memories
     try {
-      const sessionResp = await makeApiCall(`/api/v1/identity/session?platform=${encodeURIComponent(PLATFORM)}`);
+      const sessionResp = await makeApiCall(`/api/v1/identity/session${buildQuery({ platform: PLATFORM })}`);
       const sess = sessionResp.session || {};
       if (sess.id || sess.context || sess.project) {
         metadata.session_context = {
@@ -462,7 +500,7 @@ export async function handleSaveConversation(args) {
     }
 
     if (shouldChunk(content)) {
-      const result = await saveChunkedContent(content, title, tags, metadata);
+      const result = await saveChunkedContent(content, title, tags, metadata, progress);
       const isAutoGenerated = !args.conversationId && conversationId;
 
       structuredLog.info(`${toolName}: completed`, {
@@ -486,14 +524,15 @@ export async function handleSaveConversation(args) {
                 `📋 Index ID: ${result.indexId}\n\n` +
                 `📊 Content Analysis:\n` +
                 `- Conversation turns: ${metadata.conversationTurns}\n` +
-                `- Code blocks: ${metadata.codeBlockCount}\n` +
+                `- Code blocks: ${metadata.codeBlockCount}${formatCodeLanguages(metadata)}\n` +
                 `- Artifacts: ${metadata.artifactCount}\n` +
                 `- URLs: ${metadata.urlCount}\n` +
                 `- File paths: ${metadata.filePathCount}\n\n` +
                 (conversationId && isAutoGenerated ? `💡 Auto-living document: Next save with title "${title}" will UPDATE this memory\n` : '') +
                 (conversationId && !isAutoGenerated ? `✓ Use conversation ID "${conversationId}" to update this later!\n` : '') +
                 `✓ Complete conversation preserved with all context!` +
-                (metadata.artifactCount > 0 && conversationId ? `\n\n📎 **${metadata.artifactCount} artifact(s) detected.** Use save_artifact to preserve each artifact separately with conversationId="${conversationId}".` : '')
+                (metadata.artifactCount > 0 && conversationId ? `\n\n📎 **${metadata.artifactCount} artifact(s) detected.** Use save_artifact to preserve each artifact separately with conversationId="${conversationId}".` : '') +
+                (tagNote ? `\n\n${tagNote}` : '')
         }]
       };
     } else {
@@ -517,7 +556,7 @@ export async function handleSaveConversation(args) {
           `🔗 Memory ID: ${result.memoryId}\n\n` +
           `📊 Content Analysis:\n` +
           `- Conversation turns: ${metadata.conversationTurns}\n` +
-          `- Code blocks: ${metadata.codeBlockCount}\n` +
+          `- Code blocks: ${metadata.codeBlockCount}${formatCodeLanguages(metadata)}\n` +
           `- Artifacts: ${metadata.artifactCount}\n` +
           `- URLs: ${metadata.urlCount}\n\n` +
           (isAutoGenerated ? `💡 Auto-living document: Saves with title "${title}" will update this memory\n` : '') +
@@ -528,7 +567,7 @@ export async function handleSaveConversation(args) {
           `🔗 Memory ID: ${result.memoryId}\n\n` +
           `📊 Content Analysis:\n` +
           `- Conversation turns: ${metadata.conversationTurns}\n` +
-          `- Code blocks: ${metadata.codeBlockCount}\n` +
+          `- Code blocks: ${metadata.codeBlockCount}${formatCodeLanguages(metadata)}\n` +
           `- Artifacts: ${metadata.artifactCount}\n` +
           `- URLs: ${metadata.urlCount}\n` +
           `- File paths: ${metadata.filePathCount}\n\n` +
@@ -540,13 +579,14 @@ export async function handleSaveConversation(args) {
       return {
         content: [{
           type: 'text',
-          text: savedOrUpdated + formatWisdomSuggestion(result.wisdomSuggestion)
+          text: savedOrUpdated + (tagNote ? `\n\n${tagNote}` : '') + formatWisdomSuggestion(result.wisdomSuggestion)
         }]
       };
     }
 
   } catch (error) {
     const errorMsg = safeErrorMessage(error);
+    progress.onError(error, 'save_conversation');
 
     structuredLog.error(`${toolName}: failed`, {
       tool_name: toolName,
@@ -581,12 +621,18 @@ export async function handleSaveArtifact(args) {
     const title = args.title;
     const artifactType = args.type;
     const rawContent = args.content || '';
-    const content = sanitizeUnicode(rawContent);
+    const content = normalizeContent(sanitizeUnicode(rawContent), contentNormalizers);
     let rawArtifactTags = args.tags;
     if (typeof rawArtifactTags === 'string') {
       try { rawArtifactTags = JSON.parse(rawArtifactTags); } catch { rawArtifactTags = [rawArtifactTags]; }
     }
-    const tags = [...(Array.isArray(rawArtifactTags) ? rawArtifactTags : []), 'artifact', artifactType];
+    // Policy applies to the caller's tags only; the system tags must always survive
+    const tagResult = applyTagPolicy(Array.isArray(rawArtifactTags) ? rawArtifactTags : [], tagPolicy);
+    const tagNote = formatTagIssues(tagResult, tagPolicy);
+    if (tagResult.rejected) {
+      return { isError: true, content: [{ type: 'text', text: tagNote }] };
+    }
+    const tags = [...new Set([...tagResult.tags, 'artifact', artifactType])];
 
     // Validate required fields
     if (!parentConversationId || !title || !artifactType || content.length < 100) {
@@ -663,7 +709,8 @@ export async function handleSaveArtifact(args) {
           `📏 Size: ${content.length.toLocaleString()} characters\n` +
           `🔗 Linked to: ${parentConversationId}\n` +
           `🆔 Memory ID: ${memoryId}\n\n` +
-          `✓ Artifact preserved as first-class object linked to parent conversation.`
+          `✓ Artifact preserved as first-class object linked to parent conversation.` +
+          (tagNote ? `\n\n${tagNote}` : '')
       }]
     };
 
@@ -928,7 +975,111 @@ export async function handleRecallMemories(args) {
   }
 }
 
-export async function handleGetMemoryDetails(args) {
+// Batch retrieval for get_memory_details — bounded fan-out, order preserved
+const MAX_BATCH_MEMORY_IDS = 20;
+const BATCH_FETCH_CONCURRENCY = 5;
+
+/**
+ * Normalizes batch input: memoryIds may be an array, a JSON string or a
+ * comma-separated string; memoryId (if given) goes first. Deduped and capped.
+ */
+export function parseBatchMemoryIds(args) {
+  let rawIds = args.memoryIds;
+  if (typeof rawIds === 'string') {
+    try { rawIds = JSON.parse(rawIds); } catch { rawIds = rawIds.split(','); }
+  }
+  if (args.memoryId) rawIds = [args.memoryId, ...(Array.isArray(rawIds) ? rawIds : [])];
+  const allIds = [...new Set((Array.isArray(rawIds) ? rawIds : []).map(id => String(id).trim()).filter(Boolean))];
+  return { ids: allIds.slice(0, MAX_BATCH_MEMORY_IDS), requestedCount: allIds.length };
+}
+
+/**
+ * Fetches each ID via fetchOne (bounded, order-preserving) and merges the
+ * single-memory results. Shared by stdio and remote mode. fetchOne must
+ * resolve to a tool result; per-item isError is kept in structuredContent
+ * and the batch is only isError when every item failed.
+ */
+export async function fetchMemoryDetailsBatch(ids, requestedCount, fetchOne, progress = noopProgress) {
+  if (ids.length === 0) {
+    return {
+      isError: true,
+      content: [{ type: 'text', text: '❌ No memory IDs provided.\n\nPass memoryId, or memoryIds with one or more UUIDs/ordinals.' }]
+    };
+  }
+
+  structuredLog.info('get_memory_details: batch starting', {
+    tool_name: 'get_memory_details',
+    requested: requestedCount,
+    fetching: ids.length
+  });
+
+  // Each single lookup handles its own errors, so one bad ID never fails the batch
+  let completed = 0;
+  progress.onStart(ids.length, `Loading ${ids.length} memories`);
+  const results = await mapWithConcurrency(ids, BATCH_FETCH_CONCURRENCY, async (id) => {
+    const result = await fetchOne(id);
+    if (result.isError) {
+      const errorText = result.content?.find(block => block.type === 'text')?.text || 'Failed to load memory';
+      progress.onError(new Error(errorText), `Memory ${id}`);
+    }
+    progress.onItem(++completed, `Loaded ${completed}/${ids.length}`);
+    return result;
+  });
+  progress.onFinish();
+
+  const content = [];
+  results.forEach((result, index) => {
+    const status = result.isError ? ' ❌' : '';
+    content.push({ type: 'text', text: `━━━ Memory ${index + 1}/${ids.length} (${ids[index]})${status} ━━━` });
+    content.push(...(result.content || []));
+  });
+  if (requestedCount > ids.length) {
+    content.push({
+      type: 'text',
+      text: `⚠️ Only the first ${MAX_BATCH_MEMORY_IDS} of ${requestedCount} IDs were retrieved. Request the rest in another call.`
+    });
+  }
+
+  const batch = {
+    content,
+    structuredContent: {
+      memories: results.map((result, index) => ({
+        memoryId: ids[index],
+        isError: !!result.isError,
+        ...(result.structuredContent ? { data: result.structuredContent } : {})
+      }))
+    }
+  };
+  if (results.every(result => result.isError)) batch.isError = true;
+  // Widgets read _meta per memory — keep each one, in batch order
+  if (results.some(result => result._meta)) {
+    batch._meta = { 'purmemo/batch': results.map(result => result._meta || null) };
+  }
+  return batch;
+}
+
+async function getMemoryDetailsBatch(args, progress = noopProgress) {
+  const { ids, requestedCount } = parseBatchMemoryIds(args);
+  return fetchMemoryDetailsBatch(
+    ids,
+    requestedCount,
+    (id) => handleGetMemoryDetails({ ...args, memoryIds: undefined, memoryId: id }),
+    progress
+  );
+}
+
+export async function handleGetMemoryDetails(args, progress = noopProgress) {
+  if (args.memoryIds) {
+    return getMemoryDetailsBatch(args, progress);
+  }
+  // The schema can't require "one of" the two, so enforce it here
+  if (!args.memoryId) {
+    return {
+      isError: true,
+      content: [{ type: 'text', text: '❌ memoryId or memoryIds is required.\n\nPass a memory UUID (or recall ordinal like "1") as memoryId, or a list as memoryIds.' }]
+    };
+  }
+
   const toolName = 'get_memory_details';
   const requestId = `${toolName}_${Date.now()}_${Math.random().toString(36).substr(2, 6)}`;
   const startTime = Date.now();
@@ -953,6 +1104,7 @@ export async function handleGetMemoryDetails(args) {
         ? `Valid range: 1-${currentIds.length} (from last recall), or use a full UUID.`
         : 'Run recall_memories first to enable ordinal lookups, or use a full UUID.';
       return {
+        isError: true,
         content: [{
           type: 'text',
           text: `❌ Invalid memory ID: "${args.memoryId}"\n\n${hint}\n\nMemory IDs are UUIDs like: 951be873-8364-400a-8075-50e8650b67a9`
@@ -994,6 +1146,7 @@ export async function handleGetMemoryDetails(args) {
       });
 
       return {
+        isError: true,
         content: [{
           type: 'text',
           text: `❌ Memory not found or invalid response\n\nMemory ID: ${resolvedId}`
@@ -1035,6 +1188,7 @@ export async function handleGetMemoryDetails(args) {
     });
 
     return {
+      isError: true,
       content: [{
         type: 'text',
         text: `❌ Error retrieving memory: ${errorMsg}\n\nMemory ID: ${resolvedId}\n\nCheck logs for full details.`
@@ -1055,7 +1209,12 @@ export async function handleGetUserContext(args) {
     const [identityResponse, sessionResponse, recentResponse] = await Promise.allSettled([
       makeApiCall('/api/v1/auth/me'),
       makeApiCall('/api/v1/identity/session'),
-      makeApiCall('/api/v1/memories/?limit=20&sort=created_at&order=desc&include_source_types=desktop_clipboard,manual,chrome_extension', { method: 'GET' })
+      makeApiCall(`/api/v1/memories/${buildQuery({
+        limit: 20,
+        sort: 'created_at',
+        order: 'desc',
+        include_source_types: ['desktop_clipboard', 'manual', 'chrome_extension']
+      })}`, { method: 'GET' })
     ]);
 
     // Extract identity from /me response
@@ -1578,15 +1737,16 @@ export async function handleRecallPublic(args) {
   structuredLog.info(`[${requestId}] recall_public called`, { query: args.query, sort: args.sort });
 
   try {
-    const params = new URLSearchParams();
-    if (args.query) params.set('query', args.query);
-    if (args.tag) params.set('tag', args.tag);
-    if (args.platform) params.set('platform', args.platform);
-    if (args.sort) params.set('sort', args.sort);
-    params.set('page', String(args.page || 1));
-    params.set('page_size', '10');
-
-    const response = await makeApiCall(`/api/v1/memories/public?${params.toString()}`, {
+    const query = buildQuery({
+      query: args.query,
+      tag: args.tag,
+      platform: args.platform,
+      sort: args.sort,
+      page: args.page || 1,
+      page_size: 10
+    });
+
+    const response = await makeApiCall(`/api/v1/memories/public${query}`, {
       method: 'GET'
     });
 
@@ -1744,7 +1904,7 @@ export async function handleGetAcknowledgedErrors(args) {
     const minOccurrences = args.min_occurrences || 1;
 
     const response = await makeApiCall(
-      `/api/v1/admin/acknowledged-errors?limit=${limit}&level_filter=${levelFilter}&min_occurrences=${minOccurrences}`,
+      `/api/v1/admin/acknowledged-errors${buildQuery({ limit, level_filter: levelFilter, min_occurrences: minOccurrences })}`,
       { method: 'GET' }
     );
 
@@ -19,7 +19,7 @@
  */
 
 import { structuredLog } from '../lib/logger.js';
-import { makeApiCall, safeErrorMessage } from '../lib/api-client.js';
+import { makeApiCall, buildQuery, safeErrorMessage } from '../lib/api-client.js';
 
 // ============================================================================
 // Constants
@@ -429,15 +429,15 @@ export async function handleGenerateHandoffBrief(args: {
 
   try {
     // Fetch recent memories and active todos in parallel
-    const memoriesParams = new URLSearchParams({
-      limit: String(MAX_MEMORIES),
+    const memoriesQuery = buildQuery({
+      limit: MAX_MEMORIES,
       sort: 'user_updated_at',
       order: 'desc',
     });
 
     const [memoriesResponse, todosResponse] = await Promise.allSettled([
-      makeApiCall(`/api/v1/memories/?${memoriesParams}`, { method: 'GET' }),
-      makeApiCall('/api/v1/todos?limit=' + MAX_TODOS, { method: 'GET' }),
+      makeApiCall(`/api/v1/memories/${memoriesQuery}`, { method: 'GET' }),
+      makeApiCall(`/api/v1/todos${buildQuery({ limit: MAX_TODOS })}`, { method: 'GET' }),
     ]);
 
     // Parse memories
@@ -216,7 +216,8 @@ export interface RecallMemoriesArgs {
 }
 
 export interface GetMemoryDetailsArgs {
-  memoryId: string;
+  memoryId?: string;
+  memoryIds?: string[];
   includeLinkedParts?: boolean;
 }
 
@@ -249,6 +250,18 @@ export interface SingleSaveResult {
   wisdomSuggestion: WisdomSuggestion | null;
 }
 
+// ============================================================================
+// Progress Types
+// ============================================================================
+
+/** Callbacks for multi-step operations (chunked saves, batch lookups). */
+export interface ProgressReporter {
+  onStart(total: number, message?: string): void;
+  onItem(completed: number, message?: string): void;
+  onError(error: Error, message?: string): void;
+  onFinish(message?: string): void;
+}
+
 // ============================================================================
 // Auth Types
 // ============================================================================
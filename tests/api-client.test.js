@@ -0,0 +1,206 @@
+/**
+ * API Client Resilience Tests
+ *
+ * These tests verify the circuit breaker and base-URL failover used by
+ * makeApiCall, driven by an injectable clock so open, half-open, switch and
+ * recovery timing are deterministic.
+ *
+ * Total: ~11 tests
+ */
+
+import { describe, it, before, afterEach } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+const PRIMARY = 'https://api.example.test';
+const SECONDARY = 'https://api-eu.example.test';
+
+function createClock(start = 1_000_000) {
+  const clock = { time: start, now: () => clock.time, advance: (ms) => { clock.time += ms; } };
+  return clock;
+}
+
+function jsonResponse(status, body) {
+  return { ok: status >= 200 && status < 300, status, text: async () => JSON.stringify(body) };
+}
+
+describe('API Client Resilience', () => {
+  let api;
+  const originalFetch = globalThis.fetch;
+
+  before(async () => {
+    api = await import(join(__dirname, '..', 'dist', 'lib', 'api-client.js'));
+  });
+
+  afterEach(() => {
+    globalThis.fetch = originalFetch;
+  });
+
+  describe('Circuit Breaker', () => {
+    const fail = () => Promise.reject(new Error('upstream down'));
+    const succeed = () => Promise.resolve('ok');
+
+    async function openBreaker(breaker, failures) {
+      for (let i = 0; i < failures; i++) {
+        await assert.rejects(breaker.execute(fail), /upstream down/);
+      }
+    }
+
+    it('should open after the failure threshold and reject without calling fn', async () => {
+      const breaker = new api.CircuitBreaker('test', 2, 60000, createClock().now);
+      await openBreaker(breaker, 2);
+      assert.strictEqual(breaker.state, 'OPEN');
+
+      let called = false;
+      await assert.rejects(
+        breaker.execute(() => { called = true; return succeed(); }),
+        api.CircuitBreakerOpenError
+      );
+      assert.strictEqual(called, false);
+    });
+
+    it('should stay OPEN until the recovery timeout, then close on a HALF_OPEN success', async () => {
+      const clock = createClock();
+      const breaker = new api.CircuitBreaker('test', 2, 60000, clock.now);
+      await openBreaker(breaker, 2);
+
+      clock.advance(59999);
+      await assert.rejects(breaker.execute(succeed), api.CircuitBreakerOpenError);
+
+      clock.advance(1);
+      assert.strictEqual(await breaker.execute(succeed), 'ok');
+      assert.strictEqual(breaker.state, 'CLOSED');
+    });
+
+    it('should reopen with a fresh timeout when the HALF_OPEN probe fails', async () => {
+      const clock = createClock();
+      const breaker = new api.CircuitBreaker('test', 2, 60000, clock.now);
+      await openBreaker(breaker, 2);
+
+      clock.advance(60000);
+      await assert.rejects(breaker.execute(fail), /upstream down/);
+      assert.strictEqual(breaker.state, 'OPEN');
+
+      clock.advance(59999);
+      await assert.rejects(breaker.execute(succeed), api.CircuitBreakerOpenError);
+      clock.advance(1);
+      assert.strictEqual(await breaker.execute(succeed), 'ok');
+    });
+  });
+
+  describe('Base URL Failover', () => {
+    it('should switch to the fallback after the failure threshold', () => {
+      const clock = createClock();
+      const failover = new api.BaseUrlFailover([PRIMARY, SECONDARY], 3, 300000, clock.now);
+
+      failover.recordFailure(PRIMARY);
+      failover.recordFailure(PRIMARY);
+      assert.strictEqual(failover.current(), PRIMARY);
+
+      failover.recordFailure(PRIMARY);
+      assert.strictEqual(failover.current(), SECONDARY);
+      assert.strictEqual(failover.getStatus().switchedAt, new Date(clock.time).toISOString());
+    });
+
+    it('should reset the failure count on success', () => {
+      const failover = new api.BaseUrlFailover([PRIMARY, SECONDARY], 3, 300000, createClock().now);
+
+      failover.recordFailure(PRIMARY);
+      failover.recordFailure(PRIMARY);
+      failover.recordSuccess(PRIMARY);
+      failover.recordFailure(PRIMARY);
+      assert.strictEqual(failover.current(), PRIMARY);
+    });
+
+    it('should stay on the fallback until the recovery timeout, then retry the primary', () => {
+      const clock = createClock();
+      const failover = new api.BaseUrlFailover([PRIMARY, SECONDARY], 1, 300000, clock.now);
+
+      failover.recordFailure(PRIMARY);
+      // Late failures from the old URL must not move us again
+      failover.recordFailure(PRIMARY);
+      clock.advance(299999);
+      assert.strictEqual(failover.current(), SECONDARY);
+
+      clock.advance(1);
+      assert.strictEqual(failover.current(), PRIMARY);
+      assert.strictEqual(failover.getStatus().switchedAt, null);
+    });
+
+    it('should fail over again if the primary is still unhealthy after recovery', () => {
+      const clock = createClock();
+      const failover = new api.BaseUrlFailover([PRIMARY, SECONDARY], 2, 300000, clock.now);
+
+      failover.recordFailure(PRIMARY);
+      failover.recordFailure(PRIMARY);
+      clock.advance(300000);
+      assert.strictEqual(failover.current(), PRIMARY);
+
+      failover.recordFailure(PRIMARY);
+      failover.recordFailure(PRIMARY);
+      assert.strictEqual(failover.current(), SECONDARY);
+      assert.strictEqual(failover.getStatus().switchedAt, new Date(clock.time).toISOString());
+    });
+
+    it('should never switch with a single URL', () => {
+      const failover = new api.BaseUrlFailover([PRIMARY], 1, 300000, createClock().now);
+      failover.recordFailure(PRIMARY);
+      failover.recordFailure(PRIMARY);
+      assert.strictEqual(failover.current(), PRIMARY);
+    });
+
+    it('should route makeApiCall to the fallback after repeated 5xx', async () => {
+      api.initApiClient({ apiUrl: PRIMARY, fallbackUrls: [SECONDARY], resolveApiKey: () => 'test-key' });
+      const hosts = [];
+      globalThis.fetch = async (url) => {
+        hosts.push(new URL(url).origin);
+        return url.startsWith(PRIMARY) ? jsonResponse(502, { detail: 'Bad Gateway' }) : jsonResponse(200, { ok: true });
+      };
+
+      for (let i = 0; i < 3; i++) {
+        await assert.rejects(api.makeApiCall('/api/v1/memories/'), /API Error 502/);
+      }
+      const data = await api.makeApiCall('/api/v1/memories/');
+
+      assert.deepStrictEqual(data, { ok: true });
+      assert.deepStrictEqual(hosts, [PRIMARY, PRIMARY, PRIMARY, SECONDARY]);
+    });
+
+    it('should count network errors from fetch toward failover', async () => {
+      api.initApiClient({ apiUrl: PRIMARY, fallbackUrls: [SECONDARY], resolveApiKey: () => 'test-key' });
+      const hosts = [];
+      globalThis.fetch = async (url) => {
+        hosts.push(new URL(url).origin);
+        if (url.startsWith(PRIMARY)) throw new TypeError('fetch failed');
+        return jsonResponse(200, { ok: true });
+      };
+
+      for (let i = 0; i < 3; i++) {
+        await assert.rejects(api.makeApiCall('/api/v1/memories/'), /fetch failed/);
+      }
+      await api.makeApiCall('/api/v1/memories/');
+
+      assert.deepStrictEqual(hosts, [PRIMARY, PRIMARY, PRIMARY, SECONDARY]);
+    });
+
+    it('should not count errors from handling a successful response', async () => {
+      api.initApiClient({ apiUrl: PRIMARY, fallbackUrls: [SECONDARY], resolveApiKey: () => 'test-key' });
+      const hosts = [];
+      globalThis.fetch = async (url) => {
+        hosts.push(new URL(url).origin);
+        // A null JSON body makes the response logging throw a TypeError
+        return { ok: true, status: 200, text: async () => 'null' };
+      };
+
+      for (let i = 0; i < 4; i++) {
+        await assert.rejects(api.makeApiCall('/api/v1/memories/'), TypeError);
+      }
+
+      assert.deepStrictEqual(hosts, [PRIMARY, PRIMARY, PRIMARY, PRIMARY]);
+    });
+  });
+});
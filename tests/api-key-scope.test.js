@@ -0,0 +1,80 @@
+/**
+ * Per-Request API Key Scope Tests
+ *
+ * These tests verify withApiKey isolates API keys across interleaved remote
+ * requests, including work fanned out through mapWithConcurrency, by checking
+ * the Authorization header on every mocked fetch.
+ *
+ * Total: ~2 tests
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+const tick = (ms) => new Promise(resolve => setTimeout(resolve, ms));
+
+describe('Per-Request API Key Scope', () => {
+  let api;
+  let mapWithConcurrency;
+  let requests;
+  const originalFetch = globalThis.fetch;
+
+  before(async () => {
+    api = await import(join(__dirname, '..', 'dist', 'lib', 'api-client.js'));
+    ({ mapWithConcurrency } = await import(join(__dirname, '..', 'dist', 'lib', 'concurrency.js')));
+    api.initApiClient({ apiUrl: 'https://api.example.test', resolveApiKey: () => 'process-key' });
+
+    requests = [];
+    globalThis.fetch = async (url, options) => {
+      const { pathname } = new URL(url);
+      requests.push({ path: pathname, authorization: options.headers.Authorization });
+      // Vary latency so the two scopes' requests interleave
+      await tick(pathname.endsWith('/2') ? 15 : 3);
+      return { ok: true, status: 200, text: async () => JSON.stringify({ path: pathname }) };
+    };
+  });
+
+  after(() => {
+    globalThis.fetch = originalFetch;
+  });
+
+  it('should send each scope its own key across interleaved fan-out', async () => {
+    requests = [];
+    const ids = ['1', '2', '3', '4', '5', '6'];
+
+    const [fanOut, single] = await Promise.all([
+      api.withApiKey('key-alice', () =>
+        mapWithConcurrency(ids, 3, id => api.makeApiCall(`/api/v1/memories/alice/${id}`))
+      ),
+      api.withApiKey('key-bob', async () => {
+        await tick(1);
+        const first = await api.makeApiCall('/api/v1/memories/bob/1');
+        const second = await api.makeApiCall('/api/v1/memories/bob/2');
+        return [first, second];
+      })
+    ]);
+
+    assert.strictEqual(fanOut.length, 6);
+    assert.strictEqual(single.length, 2);
+    assert.strictEqual(requests.length, 8);
+    // Requests really did interleave, otherwise the test proves nothing
+    const owners = requests.map(r => r.path.split('/')[4]);
+    assert.notDeepStrictEqual(owners, [...owners].sort());
+
+    for (const { path, authorization } of requests) {
+      const owner = path.split('/')[4];
+      assert.strictEqual(authorization, `Bearer key-${owner}`, `wrong key for ${path}`);
+    }
+  });
+
+  it('should fall back to the process key outside any scope', async () => {
+    requests = [];
+    await api.makeApiCall('/api/v1/memories/none/1');
+    assert.deepStrictEqual(requests.map(r => r.authorization), ['Bearer process-key']);
+  });
+});
@@ -0,0 +1,112 @@
+/**
+ * Batch get_memory_details Tests
+ *
+ * These tests verify memoryIds parsing (arrays, JSON strings, comma lists,
+ * the 20-ID cap) and how fetchMemoryDetailsBatch merges per-memory results:
+ * order, per-item errors, all-failed batches and the truncation warning.
+ *
+ * Total: ~9 tests
+ */
+
+import { describe, it, before } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+const tick = (ms) => new Promise(resolve => setTimeout(resolve, ms));
+
+function memoryResult(id) {
+  return { content: [{ type: 'text', text: `Memory ${id}` }], structuredContent: { id } };
+}
+
+function errorResult(id) {
+  return { isError: true, content: [{ type: 'text', text: `❌ Memory ${id} not found` }] };
+}
+
+describe('Batch Memory Details', () => {
+  let parseBatchMemoryIds;
+  let fetchMemoryDetailsBatch;
+
+  before(async () => {
+    const module = await import(join(__dirname, '..', 'dist', 'tools', 'handlers.js'));
+    ({ parseBatchMemoryIds, fetchMemoryDetailsBatch } = module);
+  });
+
+  describe('parseBatchMemoryIds', () => {
+    it('should accept memoryIds as a JSON string', () => {
+      assert.deepStrictEqual(parseBatchMemoryIds({ memoryIds: '["a", "b"]' }), { ids: ['a', 'b'], requestedCount: 2 });
+    });
+
+    it('should accept memoryIds as a comma-separated string', () => {
+      assert.deepStrictEqual(parseBatchMemoryIds({ memoryIds: 'a, b,,c ' }), { ids: ['a', 'b', 'c'], requestedCount: 3 });
+    });
+
+    it('should put memoryId first and drop duplicates', () => {
+      assert.deepStrictEqual(
+        parseBatchMemoryIds({ memoryId: 'b', memoryIds: ['a', 'b', 'a'] }),
+        { ids: ['b', 'a'], requestedCount: 2 }
+      );
+    });
+
+    it('should cap at 20 IDs but report how many were requested', () => {
+      const memoryIds = Array.from({ length: 25 }, (_, i) => `id-${i}`);
+      const { ids, requestedCount } = parseBatchMemoryIds({ memoryIds });
+      assert.deepStrictEqual(ids, memoryIds.slice(0, 20));
+      assert.strictEqual(requestedCount, 25);
+    });
+  });
+
+  describe('fetchMemoryDetailsBatch', () => {
+    it('should keep input order regardless of completion order', async () => {
+      const ids = ['slow', 'fast', 'medium'];
+      const delays = { slow: 20, fast: 1, medium: 10 };
+      const result = await fetchMemoryDetailsBatch(ids, 3, async (id) => {
+        await tick(delays[id]);
+        return memoryResult(id);
+      });
+
+      assert.deepStrictEqual(result.structuredContent.memories.map(m => m.memoryId), ids);
+      assert.deepStrictEqual(result.structuredContent.memories.map(m => m.data.id), ids);
+      const headers = result.content.filter(block => block.text.startsWith('━━━'));
+      assert.deepStrictEqual(headers.map(block => block.text), [
+        '━━━ Memory 1/3 (slow) ━━━',
+        '━━━ Memory 2/3 (fast) ━━━',
+        '━━━ Memory 3/3 (medium) ━━━'
+      ]);
+    });
+
+    it('should mark only the failed item when one ID is bad', async () => {
+      const errors = [];
+      const progress = { onStart() {}, onItem() {}, onFinish() {}, onError: (error, label) => errors.push(label) };
+      const result = await fetchMemoryDetailsBatch(['a', 'bad', 'c'], 3,
+        async (id) => (id === 'bad' ? errorResult(id) : memoryResult(id)), progress);
+
+      assert.strictEqual(result.isError, undefined);
+      assert.deepStrictEqual(result.structuredContent.memories.map(m => m.isError), [false, true, false]);
+      assert.ok(result.content.some(block => block.text === '━━━ Memory 2/3 (bad) ❌ ━━━'));
+      assert.deepStrictEqual(errors, ['Memory bad']);
+    });
+
+    it('should set isError on the batch when every item fails', async () => {
+      const result = await fetchMemoryDetailsBatch(['x', 'y'], 2, async (id) => errorResult(id));
+      assert.strictEqual(result.isError, true);
+    });
+
+    it('should warn when more IDs were requested than fetched', async () => {
+      const ids = Array.from({ length: 20 }, (_, i) => `id-${i}`);
+      const result = await fetchMemoryDetailsBatch(ids, 23, async (id) => memoryResult(id));
+      const last = result.content[result.content.length - 1].text;
+      assert.strictEqual(last, '⚠️ Only the first 20 of 23 IDs were retrieved. Request the rest in another call.');
+    });
+
+    it('should return an error without calling fetchOne for an empty list', async () => {
+      let called = false;
+      const result = await fetchMemoryDetailsBatch([], 0, async () => { called = true; });
+      assert.strictEqual(result.isError, true);
+      assert.strictEqual(called, false);
+    });
+  });
+});
@@ -0,0 +1,67 @@
+/**
+ * Bounded Concurrency Tests
+ *
+ * These tests verify mapWithConcurrency, used by chunked saves and batch
+ * get_memory_details: order-preserving results, the in-flight limit, and
+ * that no new work starts after the first failure.
+ *
+ * Total: ~4 tests
+ */
+
+import { describe, it, before } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+const tick = (ms) => new Promise(resolve => setTimeout(resolve, ms));
+
+describe('Bounded Concurrency', () => {
+  let mapWithConcurrency;
+
+  before(async () => {
+    const module = await import(join(__dirname, '..', 'dist', 'lib', 'concurrency.js'));
+    mapWithConcurrency = module.mapWithConcurrency;
+  });
+
+  it('should keep results in input order regardless of completion order', async () => {
+    const results = await mapWithConcurrency([30, 5, 20, 1], 4, async (ms, index) => {
+      await tick(ms);
+      return index;
+    });
+    assert.deepStrictEqual(results, [0, 1, 2, 3]);
+  });
+
+  it('should never exceed the concurrency limit', async () => {
+    let inFlight = 0;
+    let maxInFlight = 0;
+    await mapWithConcurrency(Array.from({ length: 12 }, (_, i) => i), 3, async () => {
+      inFlight++;
+      maxInFlight = Math.max(maxInFlight, inFlight);
+      await tick(2);
+      inFlight--;
+    });
+    assert.strictEqual(maxInFlight, 3);
+  });
+
+  it('should handle empty input', async () => {
+    assert.deepStrictEqual(await mapWithConcurrency([], 3, async () => 1), []);
+  });
+
+  it('should stop starting new items after the first rejection', async () => {
+    const started = [];
+    await assert.rejects(
+      mapWithConcurrency(Array.from({ length: 8 }, (_, i) => i), 3, async (item) => {
+        started.push(item);
+        if (item === 0) throw new Error('upload failed');
+        await tick(10);
+      }),
+      /upload failed/
+    );
+    // Let the in-flight workers settle, then confirm nothing else was scheduled
+    await tick(50);
+    assert.deepStrictEqual(started.sort(), [0, 1, 2]);
+  });
+});
@@ -0,0 +1,121 @@
+/**
+ * Credential File Tests
+ *
+ * These tests verify PURMEMO_API_KEY_FILE handling: PURMEMO_API_KEY taking
+ * precedence, rotation (including an inode swap) reaching onChange, and a
+ * missing or empty file never replacing the last good key.
+ *
+ * Total: ~6 tests
+ */
+
+import { describe, it, before, beforeEach, afterEach } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+import { mkdtempSync, writeFileSync, renameSync, rmSync, statSync, utimesSync } from 'fs';
+import { tmpdir } from 'os';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+const POLL_MS = 20;
+const settle = () => new Promise(resolve => setTimeout(resolve, POLL_MS * 8));
+
+describe('Credential File', () => {
+  let credentials;
+  let dir;
+  let keyPath;
+  let stop;
+
+  before(async () => {
+    credentials = await import(join(__dirname, '..', 'dist', 'lib', 'credential-file.js'));
+  });
+
+  beforeEach(() => {
+    dir = mkdtempSync(join(tmpdir(), 'purmemo-key-'));
+    keyPath = join(dir, 'api-key');
+    stop = null;
+  });
+
+  afterEach(() => {
+    if (stop) stop();
+    rmSync(dir, { recursive: true, force: true });
+  });
+
+  describe('Key Resolution', () => {
+    it('should prefer PURMEMO_API_KEY over the key file', () => {
+      writeFileSync(keyPath, 'file-key\n');
+      const resolved = credentials.resolveConfiguredApiKey({
+        PURMEMO_API_KEY: 'env-key',
+        PURMEMO_API_KEY_FILE: keyPath
+      });
+      assert.deepStrictEqual(resolved, { key: 'env-key', source: 'env' });
+    });
+
+    it('should read and trim the key file when no env key is set', () => {
+      writeFileSync(keyPath, '  file-key\n');
+      const resolved = credentials.resolveConfiguredApiKey({ PURMEMO_API_KEY_FILE: keyPath });
+      assert.deepStrictEqual(resolved, { key: 'file-key', source: 'key_file' });
+    });
+
+    it('should resolve nothing for a missing or empty file', () => {
+      assert.deepStrictEqual(
+        credentials.resolveConfiguredApiKey({ PURMEMO_API_KEY_FILE: keyPath }),
+        { key: null, source: null }
+      );
+      writeFileSync(keyPath, '\n');
+      assert.strictEqual(credentials.readCredentialFile(keyPath), null);
+    });
+  });
+
+  describe('Watching', () => {
+    it('should report a key rewritten in place', async () => {
+      writeFileSync(keyPath, 'key-1');
+      const seen = [];
+      stop = credentials.watchCredentialFile(keyPath, key => seen.push(key), POLL_MS);
+
+      await settle();
+      writeFileSync(keyPath, 'key-2');
+      await settle();
+
+      assert.deepStrictEqual(seen, ['key-2']);
+    });
+
+    it('should report a rotation that swaps in a new inode', async () => {
+      writeFileSync(keyPath, 'key-1');
+      const { mtime } = statSync(keyPath);
+      const seen = [];
+      stop = credentials.watchCredentialFile(keyPath, key => seen.push(key), POLL_MS);
+
+      // Same mtime as the old file, so only the inode change can trigger the reload
+      const staged = join(dir, 'api-key.new');
+      writeFileSync(staged, 'key-2');
+      utimesSync(staged, mtime, mtime);
+      await settle();
+      renameSync(staged, keyPath);
+      await settle();
+
+      assert.deepStrictEqual(seen, ['key-2']);
+    });
+
+    it('should keep the last key while the file is empty or missing', async () => {
+      writeFileSync(keyPath, 'key-1');
+      const seen = [];
+      stop = credentials.watchCredentialFile(keyPath, key => seen.push(key), POLL_MS);
+
+      await settle();
+      writeFileSync(keyPath, '');
+      await settle();
+      rmSync(keyPath);
+      await settle();
+      assert.deepStrictEqual(seen, []);
+
+      // Reappearing with the same key is not a change either
+      writeFileSync(keyPath, 'key-1');
+      await settle();
+      writeFileSync(keyPath, 'key-2');
+      await settle();
+      assert.deepStrictEqual(seen, ['key-2']);
+    });
+  });
+});
@@ -0,0 +1,159 @@
+/**
+ * API Error Body Parsing Tests
+ *
+ * These tests verify parseErrorBody turns whatever an error response contains
+ * (FastAPI details, proxy HTML pages, empty or truncated bodies) into a short
+ * single-line message, and never throws on malformed input.
+ *
+ * Total: ~11 tests
+ */
+
+import { describe, it, before } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+// Seeded PRNG (mulberry32) so fuzz failures are reproducible
+function createRandom(seed) {
+  return () => {
+    seed |= 0;
+    seed = (seed + 0x6D2B79F5) | 0;
+    let t = Math.imul(seed ^ (seed >>> 15), 1 | seed);
+    t = (t + Math.imul(t ^ (t >>> 7), 61 | t)) ^ t;
+    return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+  };
+}
+
+const SAMPLE_BODIES = [
+  '{"detail":"Memory not found"}',
+  '{"detail":[{"loc":["body","title"],"msg":"field required","type":"value_error.missing"}]}',
+  '{"detail":{"message":"Quota exceeded","limit":50}}',
+  '{"error":{"code":"bad_request","message":"Invalid tag"}}',
+  '<!DOCTYPE html><html><head><title>502 Bad Gateway</title></head><body><h1>Bad Gateway</h1></body></html>',
+  '<html><body><h1>Service Unavailable</h1></body></html>',
+  'upstream connect error or disconnect/reset before headers',
+  '"plain json string"',
+  '[]',
+  '{}'
+];
+
+const FUZZ_ALPHABET = ['{', '}', '[', ']', '"', ':', ',', '<', '>', '/', '\\', 'detail', 'msg', 'loc',
+  'null', 'true', '0', ' ', '\n', '\t', '\u0000', '\u001b', '\uD800', 'é', '<title>', '</html>', 'x'];
+
+function assertWellFormed(message, input) {
+  assert.strictEqual(typeof message, 'string', `non-string for ${JSON.stringify(input)}`);
+  assert.ok(message.length > 0, `empty message for ${JSON.stringify(input)}`);
+  assert.ok(message.length <= 301, `message too long for ${JSON.stringify(input)}`);
+  assert.ok(!/[\x00-\x1F\x7F]/.test(message), `control characters in ${JSON.stringify(message)}`);
+}
+
+describe('API Error Body Parsing', () => {
+  let parseErrorBody;
+
+  before(async () => {
+    const module = await import(join(__dirname, '..', 'dist', 'lib', 'api-client.js'));
+    parseErrorBody = module.parseErrorBody;
+  });
+
+  describe('Known Shapes', () => {
+    it('should return FastAPI string detail as-is', () => {
+      assert.strictEqual(parseErrorBody('{"detail":"Memory not found"}'), 'Memory not found');
+    });
+
+    it('should flatten FastAPI validation arrays into field: message pairs', () => {
+      const body = JSON.stringify({
+        detail: [
+          { loc: ['body', 'title'], msg: 'field required' },
+          { loc: ['query', 'limit'], msg: 'ensure this value is less than 100' }
+        ]
+      });
+      assert.strictEqual(
+        parseErrorBody(body),
+        'title: field required; query.limit: ensure this value is less than 100'
+      );
+    });
+
+    it('should use message from object details and nested error objects', () => {
+      assert.strictEqual(parseErrorBody('{"detail":{"message":"Quota exceeded","limit":50}}'), 'Quota exceeded');
+      assert.strictEqual(parseErrorBody('{"error":{"code":"bad_request","message":"Invalid tag"}}'), 'Invalid tag');
+    });
+
+    it('should summarize HTML error pages by title instead of dumping markup', () => {
+      const message = parseErrorBody(SAMPLE_BODIES[4]);
+      assert.strictEqual(message, 'HTML error page: 502 Bad Gateway');
+      assert.strictEqual(parseErrorBody(SAMPLE_BODIES[5]), 'HTML error page: Service Unavailable');
+    });
+
+    it('should keep JSON validation messages that echo HTML input', () => {
+      const body = JSON.stringify({
+        detail: [{
+          loc: ['body', 'content'],
+          msg: 'String should have at most 100000 characters',
+          input: '<html><head><title>Pasted page</title></head><body></body></html>'
+        }]
+      });
+      assert.strictEqual(parseErrorBody(body), 'content: String should have at most 100000 characters');
+    });
+
+    it('should describe empty and whitespace-only bodies', () => {
+      assert.strictEqual(parseErrorBody(''), 'Empty response body');
+      assert.strictEqual(parseErrorBody('  \n '), 'Empty response body');
+      assert.strictEqual(parseErrorBody(undefined), 'Empty response body');
+    });
+
+    it('should fall back to clipped raw text for truncated JSON', () => {
+      const message = parseErrorBody('{"detail":"Database connection po');
+      assert.strictEqual(message, '{"detail":"Database connection po');
+    });
+
+    it('should clip very long bodies', () => {
+      const message = parseErrorBody('x'.repeat(5000));
+      assert.strictEqual(message.length, 301);
+      assert.ok(message.endsWith('…'));
+    });
+  });
+
+  describe('Fuzzing', () => {
+    it('should handle every truncation of known bodies', () => {
+      for (const body of SAMPLE_BODIES) {
+        for (let end = 0; end <= body.length; end++) {
+          const input = body.substring(0, end);
+          assertWellFormed(parseErrorBody(input), input);
+        }
+      }
+    });
+
+    it('should handle random token soup without throwing', () => {
+      const random = createRandom(193);
+      for (let i = 0; i < 2000; i++) {
+        const length = Math.floor(random() * 40);
+        let input = '';
+        for (let j = 0; j < length; j++) {
+          input += FUZZ_ALPHABET[Math.floor(random() * FUZZ_ALPHABET.length)];
+        }
+        assertWellFormed(parseErrorBody(input), input);
+      }
+    });
+
+    it('should handle random valid JSON values', () => {
+      const random = createRandom(4242);
+      const randomValue = (depth) => {
+        const pick = Math.floor(random() * (depth > 2 ? 4 : 6));
+        if (pick === 0) return null;
+        if (pick === 1) return random() > 0.5;
+        if (pick === 2) return Math.floor(random() * 1000);
+        if (pick === 3) return ['', 'detail', 'msg', '\u0007bell', 'x'.repeat(400)][Math.floor(random() * 5)];
+        if (pick === 4) return Array.from({ length: Math.floor(random() * 3) }, () => randomValue(depth + 1));
+        const keys = ['detail', 'message', 'error', 'loc', 'msg', 'other'];
+        return Object.fromEntries(keys.filter(() => random() > 0.6).map(key => [key, randomValue(depth + 1)]));
+      };
+      for (let i = 0; i < 1000; i++) {
+        const input = JSON.stringify(randomValue(0));
+        assertWellFormed(parseErrorBody(input), input);
+      }
+    });
+  });
+});
@@ -0,0 +1,88 @@
+/**
+ * Content Normalizer Tests
+ *
+ * These tests verify the opt-in pre-save normalizers: ANSI stripping (CSI and
+ * OSC sequences), HTML-to-Markdown conversion, and how PURMEMO_NORMALIZERS
+ * values resolve to a pipeline.
+ *
+ * Total: ~9 tests
+ */
+
+import { describe, it, before } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+describe('Content Normalizers', () => {
+  let normalizers;
+
+  before(async () => {
+    normalizers = await import(join(__dirname, '..', 'dist', 'lib', 'normalizers.js'));
+  });
+
+  describe('stripAnsi', () => {
+    it('should remove CSI color and cursor sequences', () => {
+      const input = '\u001b[1;31merror\u001b[0m: build failed\u001b[2K\u001b[1G';
+      assert.strictEqual(normalizers.stripAnsi(input), 'error: build failed');
+    });
+
+    it('should remove OSC titles and hyperlinks with either terminator', () => {
+      const title = '\u001b]0;npm test\u0007done';
+      const link = '\u001b]8;;https://example.com\u001b\\docs\u001b]8;;\u001b\\';
+      assert.strictEqual(normalizers.stripAnsi(title), 'done');
+      assert.strictEqual(normalizers.stripAnsi(link), 'docs');
+    });
+
+    it('should leave plain text and brackets alone', () => {
+      const input = 'array[0] = "[ok]"';
+      assert.strictEqual(normalizers.stripAnsi(input), input);
+    });
+  });
+
+  describe('htmlToMarkdown', () => {
+    it('should convert headings, emphasis, links and lists', () => {
+      const html = '<h2>Setup</h2><p>Run <strong>npm install</strong> then <em>restart</em>.</p>'
+        + '<ul><li>See <a href="https://example.com/docs">the docs</a></li></ul>';
+      const markdown = normalizers.htmlToMarkdown(html);
+      assert.ok(markdown.includes('## Setup'));
+      assert.ok(markdown.includes('Run **npm install** then *restart*.'));
+      assert.ok(markdown.includes('- See [the docs](https://example.com/docs)'));
+      assert.ok(!markdown.includes('<'));
+    });
+
+    it('should fence pre/code blocks and decode entities', () => {
+      const markdown = normalizers.htmlToMarkdown('<pre><code>if (a &lt; b &amp;&amp; c)</code></pre>');
+      assert.ok(markdown.includes('```\nif (a < b && c)\n```'));
+    });
+
+    it('should drop script and style bodies entirely', () => {
+      const markdown = normalizers.htmlToMarkdown('<style>p{color:red}</style><p>kept</p><script>alert(1)</script>');
+      assert.strictEqual(markdown.trim(), 'kept');
+    });
+  });
+
+  describe('resolveNormalizers', () => {
+    it('should run nothing when unset, empty or "none"', () => {
+      assert.deepStrictEqual(normalizers.resolveNormalizers(undefined), []);
+      assert.deepStrictEqual(normalizers.resolveNormalizers('  '), []);
+      assert.deepStrictEqual(normalizers.resolveNormalizers('NONE'), []);
+    });
+
+    it('should resolve names in the given order, case-insensitively', () => {
+      const resolved = normalizers.resolveNormalizers(' Whitespace, ansi ');
+      assert.deepStrictEqual(resolved, [normalizers.collapseWhitespace, normalizers.stripAnsi]);
+    });
+
+    it('should ignore unknown names', () => {
+      const resolved = normalizers.resolveNormalizers('ansi,rot13,');
+      assert.deepStrictEqual(resolved, [normalizers.stripAnsi]);
+      assert.strictEqual(
+        normalizers.normalizeContent('\u001b[32mok\u001b[0m', resolved),
+        'ok'
+      );
+    });
+  });
+});
@@ -0,0 +1,52 @@
+/**
+ * Query String Encoding Tests
+ *
+ * These tests verify buildQuery, which builds every list/search query string:
+ * empty values are skipped, arrays comma-joined, values URL-encoded.
+ *
+ * Total: ~6 tests
+ */
+
+import { describe, it, before } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+describe('Query String Encoding', () => {
+  let buildQuery;
+
+  before(async () => {
+    const module = await import(join(__dirname, '..', 'dist', 'lib', 'api-client.js'));
+    buildQuery = module.buildQuery;
+  });
+
+  it('should return an empty string when there are no params', () => {
+    assert.strictEqual(buildQuery(), '');
+    assert.strictEqual(buildQuery({}), '');
+  });
+
+  it('should skip undefined, null and empty-string values', () => {
+    assert.strictEqual(buildQuery({ a: undefined, b: null, c: '', d: 'x' }), '?d=x');
+    assert.strictEqual(buildQuery({ a: undefined, b: null, c: '' }), '');
+  });
+
+  it('should keep falsy values that are not empty', () => {
+    assert.strictEqual(buildQuery({ offset: 0, archived: false }), '?offset=0&archived=false');
+  });
+
+  it('should stringify numbers', () => {
+    assert.strictEqual(buildQuery({ limit: 20, page: 2 }), '?limit=20&page=2');
+  });
+
+  it('should comma-join arrays and drop empty ones', () => {
+    assert.strictEqual(buildQuery({ tags: ['bug-fix', 'api'], ids: [] }), '?tags=bug-fix%2Capi');
+    assert.strictEqual(buildQuery({ ids: [] }), '');
+  });
+
+  it('should URL-encode keys and values', () => {
+    assert.strictEqual(buildQuery({ query: 'a&b=c d', 'sort by': 'date' }), '?query=a%26b%3Dc+d&sort+by=date');
+  });
+});
@@ -0,0 +1,68 @@
+/**
+ * Remote Store Expiry Tests
+ *
+ * These tests verify the TTL pruning remote mode runs over its in-memory
+ * stores — idle sessions (30 min), OAuth states (10 min) and refresh tokens
+ * (24 h) — using an injected clock instead of real timers.
+ *
+ * Total: ~5 tests
+ */
+
+import { describe, it, before } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+const START = 1_000_000;
+
+describe('Remote Store Expiry', () => {
+  let expiry;
+
+  before(async () => {
+    expiry = await import(join(__dirname, '..', 'dist', 'remote', 'expiry.js'));
+  });
+
+  it('should keep refresh tokens until exactly 24 hours and drop them after', () => {
+    const store = { fresh: { token: 'r1', createdAt: START }, old: { token: 'r0', createdAt: START - 1 } };
+    const removed = expiry.pruneExpired(store, expiry.REFRESH_TOKEN_TTL_MS, () => START + expiry.REFRESH_TOKEN_TTL_MS);
+
+    assert.strictEqual(removed, 1);
+    assert.deepStrictEqual(Object.keys(store), ['fresh']);
+  });
+
+  it('should expire OAuth states after 10 minutes', () => {
+    const store = { state: { params: 'p', provider: 'google', createdAt: START } };
+    let time = START + 10 * 60 * 1000;
+
+    assert.strictEqual(expiry.pruneExpired(store, expiry.OAUTH_STATE_TTL_MS, () => time), 0);
+    time += 1;
+    assert.strictEqual(expiry.pruneExpired(store, expiry.OAUTH_STATE_TTL_MS, () => time), 1);
+    assert.deepStrictEqual(store, {});
+  });
+
+  it('should drop sessions by idle time, not age', () => {
+    const sessions = new Map([
+      ['active', { token: 'a', createdAt: START - 3_600_000, lastActivity: START }],
+      ['idle', { token: 'b', createdAt: START, lastActivity: START - 1 }]
+    ]);
+    const removed = expiry.pruneExpired(sessions, expiry.SESSION_IDLE_TTL_MS, () => START + 30 * 60 * 1000, 'lastActivity');
+
+    assert.strictEqual(removed, 1);
+    assert.deepStrictEqual([...sessions.keys()], ['active']);
+  });
+
+  it('should read the clock once per sweep', () => {
+    let calls = 0;
+    const store = { a: { createdAt: START }, b: { createdAt: START } };
+    expiry.pruneExpired(store, 1000, () => { calls++; return START; });
+    assert.strictEqual(calls, 1);
+  });
+
+  it('should handle empty stores', () => {
+    assert.strictEqual(expiry.pruneExpired({}, 1000, () => START), 0);
+    assert.strictEqual(expiry.pruneExpired(new Map(), 1000, () => START), 0);
+  });
+});
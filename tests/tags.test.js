@@ -0,0 +1,91 @@
+/**
+ * Tag Policy Tests
+ *
+ * These tests verify tag canonicalization and each PURMEMO_TAG_POLICY mode:
+ * off leaves tags alone, warn reports without changing, normalize rewrites
+ * and reports what it dropped, reject fails on any issue.
+ *
+ * Total: ~9 tests
+ */
+
+import { describe, it, before } from 'node:test';
+import assert from 'node:assert';
+import { fileURLToPath } from 'url';
+import { dirname, join } from 'path';
+
+const __filename = fileURLToPath(import.meta.url);
+const __dirname = dirname(__filename);
+
+const MESSY = ['Bug Fix', 'bug-fix', '  ', 'API', 'deploy'];
+
+describe('Tag Policy', () => {
+  let tags;
+
+  before(async () => {
+    tags = await import(join(__dirname, '..', 'dist', 'lib', 'tags.js'));
+  });
+
+  describe('normalizeTags', () => {
+    it('should trim, lowercase and hyphenate inner whitespace', () => {
+      assert.deepStrictEqual(tags.normalizeTags(['  Bug  Fix ', 'API', 'Front\tEnd']), ['bug-fix', 'api', 'front-end']);
+    });
+
+    it('should drop empties and duplicates, keeping first occurrence order', () => {
+      assert.deepStrictEqual(tags.normalizeTags(MESSY), ['bug-fix', 'api', 'deploy']);
+      assert.deepStrictEqual(tags.normalizeTags([null, undefined, '']), []);
+    });
+
+    it('should keep at most maxTags', () => {
+      const many = Array.from({ length: 25 }, (_, i) => `t${i}`);
+      assert.strictEqual(tags.normalizeTags(many).length, tags.MAX_TAGS);
+      assert.deepStrictEqual(tags.normalizeTags(many, 2), ['t0', 't1']);
+    });
+  });
+
+  describe('resolveTagPolicy', () => {
+    it('should default to off for unset or unknown values', () => {
+      assert.strictEqual(tags.resolveTagPolicy(undefined), 'off');
+      assert.strictEqual(tags.resolveTagPolicy('strict'), 'off');
+      assert.strictEqual(tags.resolveTagPolicy(' Normalize '), 'normalize');
+    });
+  });
+
+  describe('applyTagPolicy', () => {
+    it('should leave tags untouched and silent when off', () => {
+      const result = tags.applyTagPolicy(MESSY, 'off');
+      assert.deepStrictEqual(result, { tags: MESSY, issues: [], rejected: false });
+      assert.strictEqual(tags.formatTagIssues(result, 'off'), '');
+    });
+
+    it('should report issues but keep tags as given when warn', () => {
+      const result = tags.applyTagPolicy(MESSY, 'warn');
+      assert.deepStrictEqual(result.tags, MESSY);
+      assert.deepStrictEqual(result.issues, [
+        '"Bug Fix" → "bug-fix"',
+        '"bug-fix" (duplicate of "bug-fix")',
+        '"  " (empty)',
+        '"API" → "api"'
+      ]);
+      assert.strictEqual(result.rejected, false);
+    });
+
+    it('should rewrite tags and report tags dropped over the limit when normalize', () => {
+      const many = Array.from({ length: 22 }, (_, i) => `t${i}`);
+      const result = tags.applyTagPolicy(many, 'normalize');
+      assert.strictEqual(result.tags.length, 20);
+      assert.deepStrictEqual(result.issues, ['"t20" (over the 20-tag limit)', '"t21" (over the 20-tag limit)']);
+      assert.ok(tags.formatTagIssues(result, 'normalize').includes('t21'));
+    });
+
+    it('should reject only when there are issues', () => {
+      assert.strictEqual(tags.applyTagPolicy(['api', 'bug-fix'], 'reject').rejected, false);
+      const result = tags.applyTagPolicy(['API'], 'reject');
+      assert.strictEqual(result.rejected, true);
+      assert.ok(tags.formatTagIssues(result, 'reject').startsWith('❌'));
+    });
+
+    it('should agree with normalizeTags when normalizing', () => {
+      assert.deepStrictEqual(tags.applyTagPolicy(MESSY, 'normalize').tags, tags.normalizeTags(MESSY));
+    });
+  });
+});